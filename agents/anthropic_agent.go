@@ -3,39 +3,132 @@ package agents
 import (
 	"fmt"
 	"log"
+	"slices"
 
 	"github.com/frozenkro/go-agent/internal/tools"
+	"github.com/frozenkro/go-agent/internal/tools/bash"
+	"github.com/frozenkro/go-agent/internal/tools/editor"
 	"github.com/frozenkro/go-agent/models/anthropic"
 )
 
+// DEFAULT_MODEL is used by helpers such as Extract unless WithModel is given
+const DEFAULT_MODEL anthropic.Model = anthropic.SONNET_4
+
+// DEFAULT_MAX_PAUSES is how many pause_turn responses in a row are continued
+// before Run gives up
+const DEFAULT_MAX_PAUSES int = 10
+
+// DEFAULT_MAX_TOKENS is used for max_tokens unless the model's output limit is lower
+const DEFAULT_MAX_TOKENS int = 16384
+
 type AnthropicAgent struct {
 	requestContext *anthropic.AnthropicMessagesRequest
 	toolInvoker    tools.ToolInvoker
+	toolConfig     tools.ToolConfig
+	events         *eventBus
+	client         MessagesClient
+	citations      bool
+	contextWindow  int
+	compactor      Compactor
+	models         *anthropic.ModelRegistry
+
+	toolChoice             *anthropic.ToolChoice
+	turnToolChoices        map[int]anthropic.ToolChoice
+	disableParallelToolUse bool
+	// turn counts the responses handled so far
+	turn int
+	// pauses counts consecutive pause_turn responses
+	pauses    int
+	maxPauses int
+
+	system      []systemPart
+	systemCache anthropic.CacheTTL
+	projectDir  *string
+	workingDir  string
 }
 
-type AnthropicAgentOption func(*anthropic.AnthropicMessagesRequest)
+type AnthropicAgentOption func(*AnthropicAgent)
 
 func WithTools(toolNames ...anthropic.ToolName) AnthropicAgentOption {
 
-	return func(a *anthropic.AnthropicMessagesRequest) {
+	return func(a *AnthropicAgent) {
 
-		toolMap := tools.InitToolMap()
+		toolMap := tools.InitToolMap(a.toolConfig)
 
-		a.Tools = make([]anthropic.AnthropicToolSpec, len(toolNames))
-		for i, toolName := range toolNames {
+		for _, toolName := range toolNames {
 			toolMeta, err := toolMap.ToolMetaByName(toolName)
 
 			if err == nil {
-				a.Tools[i] = toolMeta.Spec
+				a.requestContext.Tools = append(a.requestContext.Tools, toolMeta.Spec)
 			} else {
-				log.Printf(err.Error())
+				log.Print(err.Error())
+			}
+		}
+	}
+}
+
+// WithServerTools enables tools run by the API, such as
+// anthropic.NewWebSearchTool(), and any beta features they require. Their
+// results arrive in the model's response and are never run locally. Their
+// settings are validated by NewAnthropicAgent.
+func WithServerTools(specs ...anthropic.AnthropicToolSpec) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		for _, spec := range specs {
+			a.requestContext.Tools = append(a.requestContext.Tools, spec)
+			if beta, ok := spec.(anthropic.BetaTool); ok && !slices.Contains(a.requestContext.Betas, beta.Beta()) {
+				a.requestContext.Betas = append(a.requestContext.Betas, beta.Beta())
 			}
 		}
 	}
 }
 
+// WithModel replaces the model the agent was created with
+func WithModel(model anthropic.Model) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.requestContext.Model = model
+	}
+}
+
+// WithMaxTokens sets the maximum number of tokens generated per response,
+// including thinking. Defaults to DefaultMaxTokens of the model.
+func WithMaxTokens(maxTokens int) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.requestContext.MaxTokens = maxTokens
+	}
+}
+
+// WithMaxPauses sets how many pause_turn responses in a row are continued.
+// Defaults to DEFAULT_MAX_PAUSES.
+func WithMaxPauses(maxPauses int) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.maxPauses = maxPauses
+	}
+}
+
+func WithBashOptions(opts ...bash.BashSessionOption) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.toolConfig.BashOptions = append(a.toolConfig.BashOptions, opts...)
+	}
+}
+
+// WithWorkingDir sets the directory the shell and text editor work in. It is
+// also {{.Cwd}} in system prompt templates and where project instructions are
+// found by default. Defaults to the current directory.
+func WithWorkingDir(dir string) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.workingDir = dir
+		a.toolConfig.BashOptions = append(a.toolConfig.BashOptions, bash.WithWorkingDir(dir))
+		a.toolConfig.EditorOptions = append(a.toolConfig.EditorOptions, editor.WithWorkingDir(dir))
+	}
+}
+
+func WithTextEditorOptions(opts ...editor.TextEditorOption) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.toolConfig.EditorOptions = append(a.toolConfig.EditorOptions, opts...)
+	}
+}
+
 func NewAnthropicAgent(model anthropic.Model, prompt string, opts ...AnthropicAgentOption) (AnthropicAgent, error) {
-	ti := tools.NewToolInvoker()
 
 	messages := []anthropic.Message{
 		anthropic.Message{
@@ -52,19 +145,53 @@ func NewAnthropicAgent(model anthropic.Model, prompt string, opts ...AnthropicAg
 	}
 
 	req := &anthropic.AnthropicMessagesRequest{
-		Model:     model,
-		MaxTokens: 1024,
-		Messages:  messages,
+		Model:    model,
+		Messages: messages,
 	}
 
+	agent := AnthropicAgent{
+		requestContext: req,
+		events:         newEventBus(),
+		compactor:      ClearToolResults(DEFAULT_KEEP_MESSAGES),
+		models:         anthropic.DefaultModels,
+		maxPauses:      DEFAULT_MAX_PAUSES,
+	}
 	for _, opt := range opts {
-		opt(req)
+		opt(&agent)
+	}
+	info, err := agent.models.Lookup(req.Model)
+	if err != nil {
+		return agent, err
+	}
+	agent.applyModelDefaults(info)
+	if err := validateModel(req, info); err != nil {
+		return agent, err
+	}
+	if err := validateThinking(req); err != nil {
+		return agent, err
+	}
+	if err := validateSampling(req); err != nil {
+		return agent, err
 	}
+	if req.System, err = agent.buildSystemPrompt(); err != nil {
+		return agent, err
+	}
+	if err := agent.validateToolChoices(); err != nil {
+		return agent, err
+	}
+	agent.applyToolChoice()
+	if agent.citations {
+		enableCitations(req.Messages)
+	}
+	agent.toolConfig.BashOptions = append(agent.toolConfig.BashOptions, bash.WithOutputHandler(agent.events.toolOutput))
+	agent.toolInvoker = tools.NewToolInvoker(agent.toolConfig)
 
-	return AnthropicAgent{
-		requestContext: req,
-		toolInvoker:    ti,
-	}, nil
+	return agent, nil
+}
+
+// Close ends shell sessions and background jobs started by the agent's tools
+func (a *AnthropicAgent) Close() error {
+	return a.toolInvoker.Close()
 }
 
 func (a *AnthropicAgent) GetRequest() *anthropic.AnthropicMessagesRequest {
@@ -74,11 +201,30 @@ func (a *AnthropicAgent) GetRequest() *anthropic.AnthropicMessagesRequest {
 func (a *AnthropicAgent) HandleResponse(response *anthropic.MessagesResponse) (*anthropic.AnthropicMessagesRequest, bool, error) {
 	complete := false
 
-	sysMsg := anthropic.Message{
-		Role:    anthropic.ASSISTANT,
-		Content: response.Content,
+	// Thinking blocks stay in the assistant message unchanged, as the API
+	// verifies their signatures when tool results are sent back
+	if a.pauses > 0 {
+		// The response continues the paused assistant message, which was
+		// sent last, so its content is added to that message
+		last := &a.requestContext.Messages[len(a.requestContext.Messages)-1]
+		last.Content = append(last.Content, response.Content...)
+	} else {
+		sysMsg := anthropic.Message{
+			Role:    anthropic.ASSISTANT,
+			Content: response.Content,
+		}
+		a.requestContext.Messages = append(a.requestContext.Messages, sysMsg)
+	}
+	a.events.thinking(response.Content)
+
+	if response.StopReason == anthropic.SR_PAUSE_TURN {
+		a.pauses++
+		if a.pauses > a.maxPauses {
+			return a.requestContext, complete, fmt.Errorf("Turn was paused more than %v times in a row", a.maxPauses)
+		}
+	} else {
+		a.pauses = 0
 	}
-	a.requestContext.Messages = append(a.requestContext.Messages, sysMsg)
 
 	// TODO Handle these reasons appropriately
 	switch response.StopReason {
@@ -89,7 +235,9 @@ func (a *AnthropicAgent) HandleResponse(response *anthropic.MessagesResponse) (*
 	case anthropic.SR_STOP_SEQUENCE:
 		complete = true
 	case anthropic.SR_PAUSE_TURN:
-		complete = true
+		// A long running server tool turn was paused. Sending the request
+		// again, ending with the paused assistant message as is, lets the
+		// model continue it.
 	case anthropic.SR_REFUSAL:
 		complete = true
 	case anthropic.SR_TOOL_USE:
@@ -100,6 +248,8 @@ func (a *AnthropicAgent) HandleResponse(response *anthropic.MessagesResponse) (*
 		a.requestContext.Messages = append(a.requestContext.Messages, usrMsg)
 	}
 
+	a.turn++
+	a.applyToolChoice()
 	return a.requestContext, complete, nil
 }
 
@@ -118,7 +268,9 @@ func (a *AnthropicAgent) getToolCallResponses(content []anthropic.Content) (anth
 				return usrMsg, fmt.Errorf("Response content did not properly parse")
 			}
 
+			a.events.toolStarted(*toolUseContent)
 			toolResultContent, err := a.toolInvoker.Invoke(*toolUseContent)
+			a.events.toolFinished(toolResultContent)
 			if err != nil {
 				return usrMsg, fmt.Errorf("Error occurred during tool invocation for tool '%v':\n%w", toolUseContent.Name, err)
 			}
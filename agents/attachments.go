@@ -0,0 +1,43 @@
+package agents
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// WithAttachments adds images or documents to the prompt. They are placed
+// before the prompt text, as the model answers best with documents first.
+func WithAttachments(content ...anthropic.Content) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		prompt := &a.requestContext.Messages[0]
+		prompt.Content = append(append([]anthropic.Content{}, content...), prompt.Content...)
+	}
+}
+
+// LoadAttachment reads an image, PDF or text file as a content block for
+// WithAttachments
+func LoadAttachment(path string) (anthropic.Content, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read attachment: %w", err)
+	}
+
+	mediaType := anthropic.DetectMediaType(path, data)
+	switch {
+	case anthropic.IsImageMediaType(mediaType):
+		return anthropic.NewImageContent(mediaType, data), nil
+	case mediaType == anthropic.MEDIA_PDF:
+		document := anthropic.NewPdfDocumentContent(data)
+		document.Title = filepath.Base(path)
+		return document, nil
+	case mediaType == anthropic.MEDIA_TEXT:
+		document := anthropic.NewTextDocumentContent(string(data))
+		document.Title = filepath.Base(path)
+		return document, nil
+	default:
+		return nil, fmt.Errorf("Unsupported attachment '%v' of type '%v'. Images, PDFs and text files can be attached", path, mediaType)
+	}
+}
@@ -0,0 +1,30 @@
+package agents
+
+import "github.com/frozenkro/go-agent/models/anthropic"
+
+// CitedSpan is a part of RunResult.Text backed by document citations. Start
+// and End are byte offsets into the text.
+type CitedSpan struct {
+	Start     int
+	End       int
+	Text      string
+	Citations []anthropic.Citation
+}
+
+// WithCitations enables citations for every document attached to the prompt,
+// so answers report which document spans they are based on
+func WithCitations() AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.citations = true
+	}
+}
+
+func enableCitations(messages []anthropic.Message) {
+	for _, m := range messages {
+		for _, c := range m.Content {
+			if document, ok := c.(*anthropic.DocumentContent); ok {
+				document.Citations = &anthropic.CitationsConfig{Enabled: true}
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+const (
+	DEFAULT_CONTEXT_WINDOW int = 200000
+	// Requests estimated below this fraction of the window are not counted exactly
+	PREFLIGHT_THRESHOLD float64 = 0.8
+	// Tool results in this many of the latest messages are never cleared
+	DEFAULT_KEEP_MESSAGES int    = 2
+	CLEARED_TOOL_RESULT   string = "[Output removed to save context]"
+)
+
+// TokenCounter counts the input tokens of a request. It is implemented by
+// clients.AnthropicClient.
+type TokenCounter interface {
+	CountTokens(context.Context, *anthropic.AnthropicMessagesRequest) (*anthropic.TokenCount, error)
+}
+
+// Compactor shrinks the conversation of a request that uses tokens input
+// tokens so it fits in limit. It reports whether it changed anything.
+type Compactor func(request *anthropic.AnthropicMessagesRequest, tokens int, limit int) bool
+
+// WithContextWindow sets the context window of the model. Defaults to the
+// model's context window in the registry, or DEFAULT_CONTEXT_WINDOW.
+func WithContextWindow(tokens int) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.contextWindow = tokens
+	}
+}
+
+// WithCompactor sets how the conversation is shrunk when it no longer fits
+// in the context window. Defaults to ClearToolResults(DEFAULT_KEEP_MESSAGES).
+func WithCompactor(compactor Compactor) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.compactor = compactor
+	}
+}
+
+// ClearToolResults returns a Compactor that replaces the output of tool
+// results, oldest first, with a short note. Results in the last keep
+// messages are left alone.
+func ClearToolResults(keep int) Compactor {
+	return func(request *anthropic.AnthropicMessagesRequest, tokens int, limit int) bool {
+		changed := false
+		for _, m := range request.Messages[:max(len(request.Messages)-keep, 0)] {
+			for i, c := range m.Content {
+				// Results created by the agent are values, decoded ones pointers
+				result, ok := c.(anthropic.ToolResultContent)
+				if p, isPointer := c.(*anthropic.ToolResultContent); isPointer {
+					result, ok = *p, true
+				}
+				if !ok || result.Text() == CLEARED_TOOL_RESULT {
+					continue
+				}
+				saved := anthropic.EstimateTokens(&anthropic.AnthropicMessagesRequest{Messages: []anthropic.Message{{Content: result.Content}}})
+				result.Content = []anthropic.Content{anthropic.NewTextContent(CLEARED_TOOL_RESULT)}
+				m.Content[i] = result
+				changed = true
+
+				tokens -= saved
+				if tokens <= limit {
+					return true
+				}
+			}
+		}
+		return changed
+	}
+}
+
+// CountTokens returns the input tokens of the agent's next request, counted
+// by the API when the client supports it and estimated locally otherwise
+func (a *AnthropicAgent) CountTokens(ctx context.Context) int {
+	return a.countTokens(ctx, a.requestContext)
+}
+
+func (a *AnthropicAgent) countTokens(ctx context.Context, request *anthropic.AnthropicMessagesRequest) int {
+	estimate := anthropic.EstimateTokens(request)
+	counter, ok := a.client.(TokenCounter)
+	if !ok {
+		return estimate
+	}
+
+	count, err := counter.CountTokens(ctx, request)
+	if err != nil {
+		log.Printf("Unable to count tokens, using an estimate: %v", err)
+		return estimate
+	}
+	return count.InputTokens
+}
+
+// fitContext compacts the conversation until the request and its response
+// fit in the context window
+func (a *AnthropicAgent) fitContext(ctx context.Context, request *anthropic.AnthropicMessagesRequest) error {
+	limit := a.contextWindow - request.MaxTokens
+	if float64(anthropic.EstimateTokens(request)) < float64(limit)*PREFLIGHT_THRESHOLD {
+		return nil
+	}
+
+	for {
+		tokens := a.countTokens(ctx, request)
+		if tokens <= limit {
+			return nil
+		}
+		if !a.compactor(request, tokens, limit) {
+			return fmt.Errorf("Conversation of %v tokens does not fit in the context window of %v tokens with max_tokens of %v", tokens, a.contextWindow, request.MaxTokens)
+		}
+	}
+}
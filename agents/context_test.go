@@ -0,0 +1,97 @@
+package agents
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/frozenkro/go-agent/clients/anthropictest"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func toolResultMessage(id string) anthropic.Message {
+	return anthropic.Message{Role: anthropic.USER, Content: []anthropic.Content{anthropic.ToolResultContent{
+		BaseContent: anthropic.BaseContent{Type: anthropic.TOOL_RESULT},
+		ToolUseId:   id,
+		Content:     []anthropic.Content{anthropic.NewTextContent(strings.Repeat("a", 6000))},
+	}}}
+}
+
+func textMessage(role anthropic.Role, text string) anthropic.Message {
+	return anthropic.Message{Role: role, Content: []anthropic.Content{anthropic.NewTextContent(text)}}
+}
+
+func TestFitContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []anthropic.Message
+		counts   int
+		cleared  []bool
+		err      bool
+	}{
+		{
+			name:     "small request is not counted",
+			messages: []anthropic.Message{textMessage(anthropic.USER, "Hello")},
+		},
+		{
+			name: "oldest tool results are cleared first",
+			messages: []anthropic.Message{
+				textMessage(anthropic.USER, "Run the tests"),
+				toolResultMessage("toolu_1"),
+				textMessage(anthropic.ASSISTANT, "Running them again"),
+				toolResultMessage("toolu_2"),
+				textMessage(anthropic.ASSISTANT, "Done"),
+				textMessage(anthropic.USER, "Thanks"),
+			},
+			counts:  2,
+			cleared: []bool{true, false},
+		},
+		{
+			name: "recent tool results are kept",
+			messages: []anthropic.Message{
+				textMessage(anthropic.USER, "Run the tests"),
+				toolResultMessage("toolu_1"),
+				toolResultMessage("toolu_2"),
+			},
+			counts:  1,
+			cleared: []bool{false, false},
+			err:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := anthropictest.NewServer()
+			defer srv.Close()
+			// Every tool result that has not been cleared counts 1000 tokens
+			counts := 0
+			srv.CountTokens = func(req *anthropictest.Request, body []byte) int {
+				counts++
+				return 50 + 1000*strings.Count(string(body), strings.Repeat("a", 6000))
+			}
+
+			agent, err := NewAnthropicAgent(anthropic.SONNET_4, "Hello", WithClient(testClient(srv)), WithContextWindow(2000))
+			if err != nil {
+				t.Fatal(err)
+			}
+			request := &anthropic.AnthropicMessagesRequest{Model: anthropic.SONNET_4, MaxTokens: 500, Messages: tt.messages}
+
+			err = agent.fitContext(context.Background(), request)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if counts != tt.counts {
+				t.Errorf("Expected %v token counts, got %v", tt.counts, counts)
+			}
+			cleared := []bool{}
+			for _, m := range request.Messages {
+				if result, ok := m.Content[0].(anthropic.ToolResultContent); ok {
+					cleared = append(cleared, result.Text() == CLEARED_TOOL_RESULT)
+				}
+			}
+			if len(tt.cleared) > 0 && !slices.Equal(cleared, tt.cleared) {
+				t.Errorf("Expected cleared results %v, got %v", tt.cleared, cleared)
+			}
+		})
+	}
+}
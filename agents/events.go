@@ -0,0 +1,128 @@
+package agents
+
+import (
+	"sync"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+type EventType string
+
+const (
+	EVENT_TOOL_START  EventType = "tool_start"
+	EVENT_TOOL_OUTPUT EventType = "tool_output"
+	EVENT_TOOL_END    EventType = "tool_end"
+	EVENT_THINKING    EventType = "thinking"
+)
+
+const EVENT_BUFFER_SIZE int = 256
+
+// Event describes progress while the agent runs tools, and the model's
+// thinking when extended thinking is enabled. Tool output events
+// carry chunks of output as it is produced; the final result sent to the model
+// is unaffected.
+type Event struct {
+	Type      EventType
+	ToolUseId string
+	ToolName  anthropic.ToolName
+	Input     any
+	Data      string
+	IsError   bool
+}
+
+type eventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]*subscriber
+	current     anthropic.ToolUseContent
+	// publishMu keeps events in order when several goroutines publish
+	publishMu sync.Mutex
+}
+
+type subscriber struct {
+	done chan struct{}
+	// sending counts publishes that may still send on the channel
+	sending sync.WaitGroup
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subscribers: make(map[chan Event]*subscriber),
+	}
+}
+
+// Subscribe returns a stream of the agent's events and a function that ends
+// the subscription. Events are delivered in order; subscribers must keep
+// receiving until they unsubscribe, as a full channel blocks the agent.
+func (a *AnthropicAgent) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, EVENT_BUFFER_SIZE)
+	sub := &subscriber{done: make(chan struct{})}
+
+	a.events.mu.Lock()
+	a.events.subscribers[ch] = sub
+	a.events.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			// Release a publish blocked on this subscriber, then wait for it
+			// so the channel is not closed while it sends
+			close(sub.done)
+			a.events.mu.Lock()
+			delete(a.events.subscribers, ch)
+			a.events.mu.Unlock()
+			sub.sending.Wait()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// publish sends e to every subscriber. The subscribers are collected under
+// the lock and sent to outside it, so a slow subscriber does not block
+// subscribing, unsubscribing or tracking the current tool.
+func (b *eventBus) publish(e Event) {
+	b.publishMu.Lock()
+	defer b.publishMu.Unlock()
+
+	b.mu.Lock()
+	channels := make(map[chan Event]*subscriber, len(b.subscribers))
+	for ch, sub := range b.subscribers {
+		sub.sending.Add(1)
+		channels[ch] = sub
+	}
+	b.mu.Unlock()
+
+	for ch, sub := range channels {
+		select {
+		case ch <- e:
+		case <-sub.done:
+		}
+		sub.sending.Done()
+	}
+}
+
+// toolStarted records the tool use that subsequent output belongs to
+func (b *eventBus) toolStarted(toolUse anthropic.ToolUseContent) {
+	b.mu.Lock()
+	b.current = toolUse
+	b.mu.Unlock()
+
+	b.publish(Event{Type: EVENT_TOOL_START, ToolUseId: toolUse.Id, ToolName: toolUse.Name, Input: toolUse.Input})
+}
+
+func (b *eventBus) toolOutput(chunk string) {
+	b.mu.Lock()
+	current := b.current
+	b.mu.Unlock()
+
+	b.publish(Event{Type: EVENT_TOOL_OUTPUT, ToolUseId: current.Id, ToolName: current.Name, Data: chunk})
+}
+
+func (b *eventBus) toolFinished(result anthropic.ToolResultContent) {
+	b.mu.Lock()
+	current := b.current
+	b.current = anthropic.ToolUseContent{}
+	b.mu.Unlock()
+
+	b.publish(Event{Type: EVENT_TOOL_END, ToolUseId: current.Id, ToolName: current.Name, Data: result.Text(), IsError: result.IsError})
+}
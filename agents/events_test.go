@@ -0,0 +1,63 @@
+package agents
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublishToBlockedSubscriber(t *testing.T) {
+	a := &AnthropicAgent{events: newEventBus()}
+	events, unsubscribe := a.Subscribe()
+
+	// Nothing receives, so publishing blocks once the buffer is full
+	published := make(chan struct{})
+	go func() {
+		for range EVENT_BUFFER_SIZE + 1 {
+			a.events.publish(Event{Type: EVENT_TOOL_OUTPUT, Data: "chunk"})
+		}
+		close(published)
+	}()
+	for len(events) < EVENT_BUFFER_SIZE {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(time.Millisecond * 10)
+
+	subscribed := make(chan struct{})
+	go func() {
+		_, unsubscribeOther := a.Subscribe()
+		unsubscribeOther()
+		close(subscribed)
+	}()
+	select {
+	case <-subscribed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected subscribing not to wait for a blocked subscriber")
+	}
+
+	unsubscribe()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Expected unsubscribing to release the blocked publish")
+	}
+}
+
+func TestUnsubscribeDrainsEvents(t *testing.T) {
+	a := &AnthropicAgent{events: newEventBus()}
+	events, unsubscribe := a.Subscribe()
+
+	for range 3 {
+		a.events.publish(Event{Type: EVENT_TOOL_OUTPUT, Data: "chunk"})
+	}
+	unsubscribe()
+	// Unsubscribing twice is harmless
+	unsubscribe()
+
+	received := 0
+	for range events {
+		received++
+	}
+	if received != 3 {
+		t.Errorf("Expected the 3 buffered events before the channel closed, got %v", received)
+	}
+}
@@ -0,0 +1,230 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/frozenkro/go-agent/clients"
+	"github.com/frozenkro/go-agent/models/anthropic"
+	toolschema "github.com/frozenkro/go-agent/models/anthropic/tool_schema"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	EXTRACT_TOOL anthropic.ToolName = "record_result"
+	// Attempts made before Extract gives up on invalid input
+	MAX_EXTRACT_ATTEMPTS int = 3
+)
+
+// Validator is implemented by extraction targets with checks beyond their
+// JSON Schema
+type Validator interface {
+	Validate() error
+}
+
+// Extract asks the model to answer prompt by calling a tool whose input
+// schema is generated from T, and returns the input decoded into T. Input
+// that does not match the schema, or fails T's Validate method, is sent back
+// to the model as a tool error, up to MAX_EXTRACT_ATTEMPTS times. The model
+// defaults to DEFAULT_MODEL.
+func Extract[T any](ctx context.Context, prompt string, opts ...AnthropicAgentOption) (T, error) {
+	var result T
+	schema, err := toolschema.For[T]()
+	if err != nil {
+		return result, err
+	}
+
+	tool := anthropic.NewCustomTool(EXTRACT_TOOL, "Record the result requested by the user. The input must match the schema exactly.", schema)
+	forced := anthropic.NewToolChoiceTool(EXTRACT_TOOL)
+	opts = append(opts, withToolSpec(tool), WithTurnToolChoice(0, forced), WithDisableParallelToolUse())
+	agent, err := NewAnthropicAgent(DEFAULT_MODEL, prompt, opts...)
+	if err != nil {
+		return result, err
+	}
+	defer agent.Close()
+	if agent.client == nil {
+		agent.client = clients.NewAnthropicClient()
+	}
+
+	request := agent.GetRequest()
+	for attempt := 1; ; attempt++ {
+		if err := agent.fitContext(ctx, request); err != nil {
+			return result, err
+		}
+		response, err := agent.client.PostMessage(ctx, request)
+		if err != nil {
+			return result, err
+		}
+		if response.StopReason == anthropic.SR_MAX_TOKENS {
+			return result, fmt.Errorf("Response reached max_tokens (%v) before the extraction was complete", request.MaxTokens)
+		}
+		request.Messages = append(request.Messages, anthropic.Message{Role: anthropic.ASSISTANT, Content: response.Content})
+
+		toolUse := extractToolUse(response.Content)
+		if toolUse == nil {
+			return result, fmt.Errorf("Model did not call the %v tool", EXTRACT_TOOL)
+		}
+		err = decodeExtraction(schema, toolUse.Input, &result)
+		if err == nil {
+			return result, nil
+		}
+		if attempt >= MAX_EXTRACT_ATTEMPTS {
+			return result, fmt.Errorf("Extraction failed validation after %v attempts:\n%w", attempt, err)
+		}
+
+		// Extract does not advance the agent's turns, so each retry is
+		// forced like the first request
+		if err := agent.SetNextToolChoice(forced); err != nil {
+			return result, err
+		}
+		request.Messages = append(request.Messages, anthropic.Message{
+			Role: anthropic.USER,
+			Content: []anthropic.Content{anthropic.ToolResultContent{
+				BaseContent: anthropic.BaseContent{Type: anthropic.TOOL_RESULT},
+				ToolUseId:   toolUse.Id,
+				Content:     []anthropic.Content{anthropic.NewTextContent("Invalid input, call the tool again with these errors fixed:\n" + err.Error())},
+				IsError:     true,
+			}},
+		})
+	}
+}
+
+// withToolSpec registers a tool the agent does not run itself
+func withToolSpec(spec anthropic.AnthropicToolSpec) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.requestContext.Tools = append(a.requestContext.Tools, spec)
+	}
+}
+
+func extractToolUse(content []anthropic.Content) *anthropic.ToolUseContent {
+	for _, c := range content {
+		if toolUse, ok := c.(*anthropic.ToolUseContent); ok && toolUse.Name == EXTRACT_TOOL {
+			return toolUse
+		}
+	}
+	return nil
+}
+
+// decodeExtraction checks input against schema and decodes it into result
+func decodeExtraction[T any](schema map[string]any, input any, result *T) error {
+	if errs := validateInput(schema, input, "input"); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	var decoded T
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName:    "json",
+		Squash:     true,
+		DecodeHook: mapstructure.StringToTimeHookFunc(time.RFC3339),
+		Result:     &decoded,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(input); err != nil {
+		return err
+	}
+
+	if v, ok := any(&decoded).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	*result = decoded
+	return nil
+}
+
+// validateInput returns every way value, found at path, does not match the
+// subset of JSON Schema generated by toolschema
+func validateInput(schema map[string]any, value any, path string) []error {
+	if value == nil {
+		return nil
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return []error{fmt.Errorf("%v must be an object", path)}
+		}
+		return validateObject(schema, obj, path)
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return []error{fmt.Errorf("%v must be an array", path)}
+		}
+		items, _ := schema["items"].(map[string]any)
+		errs := []error{}
+		for i, item := range arr {
+			errs = append(errs, validateInput(items, item, fmt.Sprintf("%v[%v]", path, i))...)
+		}
+		return errs
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return []error{fmt.Errorf("%v must be a string", path)}
+		}
+		if enum, ok := schema["enum"].([]string); ok && !slices.Contains(enum, s) {
+			return []error{fmt.Errorf("%v must be one of %v, got %q", path, enum, s)}
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return []error{fmt.Errorf("%v must be an RFC 3339 date-time, got %q", path, s)}
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return []error{fmt.Errorf("%v must be an integer", path)}
+		}
+		if minimum, ok := schema["minimum"].(int); ok && n < float64(minimum) {
+			return []error{fmt.Errorf("%v must be at least %v", path, minimum)}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []error{fmt.Errorf("%v must be a number", path)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []error{fmt.Errorf("%v must be a boolean", path)}
+		}
+	}
+	return nil
+}
+
+func validateObject(schema map[string]any, obj map[string]any, path string) []error {
+	errs := []error{}
+	required, _ := schema["required"].([]string)
+	for _, name := range required {
+		if obj[name] == nil {
+			errs = append(errs, fmt.Errorf("%v.%v is required", path, name))
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if property, ok := properties[key].(map[string]any); ok {
+			errs = append(errs, validateInput(property, obj[key], path+"."+key)...)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				errs = append(errs, fmt.Errorf("%v.%v is not a known field", path, key))
+			}
+		case map[string]any:
+			errs = append(errs, validateInput(additional, obj[key], path+"."+key)...)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,127 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/frozenkro/go-agent/clients/anthropictest"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+type forecast struct {
+	City       string  `json:"city"`
+	TempC      float64 `json:"temp_c"`
+	Conditions string  `json:"conditions" enum:"sunny,cloudy,rain"`
+	Wind       *int    `json:"wind"`
+}
+
+func (f *forecast) Validate() error {
+	if f.TempC < -90 || f.TempC > 60 {
+		return fmt.Errorf("temp_c of %v is not a plausible temperature", f.TempC)
+	}
+	return nil
+}
+
+func extraction(input map[string]any) anthropictest.Step {
+	return anthropictest.Reply(anthropic.SR_TOOL_USE, anthropictest.ToolUse("toolu_1", EXTRACT_TOOL, input))
+}
+
+// forcedRetry checks a retry is forced to the tool again and reports the
+// error to the model
+func forcedRetry(errText string) func(*anthropictest.Request) error {
+	return func(r *anthropictest.Request) error {
+		choice, _ := r.Raw["tool_choice"].(map[string]any)
+		if choice["type"] != "tool" || choice["name"] != string(EXTRACT_TOOL) {
+			return fmt.Errorf("Expected the retry to force %v, got %v", EXTRACT_TOOL, choice)
+		}
+		last := r.Messages[len(r.Messages)-1]
+		result, ok := last.Content[0].(*anthropic.ToolResultContent)
+		if !ok || !result.IsError || !strings.Contains(result.Text(), errText) {
+			return fmt.Errorf("Expected a tool error containing %q, got %+v", errText, last.Content[0])
+		}
+		return nil
+	}
+}
+
+func TestExtract(t *testing.T) {
+	valid := map[string]any{"city": "Oslo", "temp_c": 4.5, "conditions": "rain"}
+
+	tests := []struct {
+		name  string
+		steps []anthropictest.Step
+		want  forecast
+		err   string
+	}{
+		{
+			name:  "valid on the first attempt",
+			steps: []anthropictest.Step{extraction(valid)},
+			want:  forecast{City: "Oslo", TempC: 4.5, Conditions: "rain"},
+		},
+		{
+			name: "schema errors are retried",
+			steps: []anthropictest.Step{
+				extraction(map[string]any{"city": "Oslo", "temp_c": "cold", "conditions": "snow", "humidity": 80}),
+				extraction(valid).Expect(forcedRetry("input.conditions must be one of [sunny cloudy rain]")),
+			},
+			want: forecast{City: "Oslo", TempC: 4.5, Conditions: "rain"},
+		},
+		{
+			name: "missing fields are retried",
+			steps: []anthropictest.Step{
+				extraction(map[string]any{"city": "Oslo"}),
+				extraction(valid).Expect(forcedRetry("input.temp_c is required")),
+			},
+			want: forecast{City: "Oslo", TempC: 4.5, Conditions: "rain"},
+		},
+		{
+			name: "Validate errors are retried",
+			steps: []anthropictest.Step{
+				extraction(map[string]any{"city": "Oslo", "temp_c": 400, "conditions": "sunny"}),
+				extraction(valid).Expect(forcedRetry("not a plausible temperature")),
+			},
+			want: forecast{City: "Oslo", TempC: 4.5, Conditions: "rain"},
+		},
+		{
+			name: "gives up after the last attempt",
+			steps: []anthropictest.Step{
+				extraction(map[string]any{"city": "Oslo"}),
+				extraction(map[string]any{"city": "Oslo"}),
+				extraction(map[string]any{"city": "Oslo"}),
+			},
+			err: fmt.Sprintf("after %v attempts", MAX_EXTRACT_ATTEMPTS),
+		},
+		{
+			name:  "tool not called",
+			steps: []anthropictest.Step{anthropictest.Reply(anthropic.SR_END_TURN, anthropictest.Text("It is raining in Oslo"))},
+			err:   "did not call the record_result tool",
+		},
+		{
+			name:  "max tokens reached",
+			steps: []anthropictest.Step{anthropictest.Reply(anthropic.SR_MAX_TOKENS, anthropictest.Text("The weather"))},
+			err:   "reached max_tokens",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := anthropictest.NewServer(tt.steps...)
+			defer srv.Close()
+
+			got, err := Extract[forecast](context.Background(), "What is the weather in Oslo?", WithClient(testClient(srv)))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.err, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got.City != tt.want.City || got.TempC != tt.want.TempC || got.Conditions != tt.want.Conditions || got.Wind != nil {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+			if err := srv.Verify(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package agents
+
+import (
+	"context"
+	"slices"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// FileDownloader saves files from the Files API. It is implemented by
+// clients.AnthropicClient.
+type FileDownloader interface {
+	DownloadFileTo(ctx context.Context, fileId string, dir string) (string, error)
+}
+
+// WithFiles attaches files uploaded with the Files API to the prompt. Images
+// and documents are shown to the model; other files are uploaded to the code
+// execution container.
+func WithFiles(files ...anthropic.File) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		content := make([]anthropic.Content, len(files))
+		for i, f := range files {
+			content[i] = f.Content()
+		}
+		WithAttachments(content...)(a)
+
+		if len(files) > 0 && !slices.Contains(a.requestContext.Betas, anthropic.BETA_FILES_API) {
+			a.requestContext.Betas = append(a.requestContext.Betas, anthropic.BETA_FILES_API)
+		}
+	}
+}
+
+// SaveOutputFiles downloads the files written by code execution during a run
+// into dir and returns their paths
+func SaveOutputFiles(ctx context.Context, files FileDownloader, result *RunResult, dir string) ([]string, error) {
+	paths := []string{}
+	for _, id := range result.OutputFiles {
+		path, err := files.DownloadFileTo(ctx, id, dir)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
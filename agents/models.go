@@ -0,0 +1,70 @@
+package agents
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// WithModelRegistry sets the registry the model's capabilities and limits
+// are looked up in. Defaults to anthropic.DefaultModels.
+func WithModelRegistry(registry *anthropic.ModelRegistry) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.models = registry
+	}
+}
+
+// UNKNOWN_MODEL_MAX_TOKENS is the default max_tokens of models without a
+// known output limit, the lowest limit of any Claude model
+const UNKNOWN_MODEL_MAX_TOKENS int = 4096
+
+// DefaultMaxTokens returns DEFAULT_MAX_TOKENS, or the model's max output
+// tokens if that is lower
+func DefaultMaxTokens(info anthropic.ModelInfo) int {
+	if info.MaxOutputTokens > 0 {
+		return min(DEFAULT_MAX_TOKENS, info.MaxOutputTokens)
+	}
+	return UNKNOWN_MODEL_MAX_TOKENS
+}
+
+// applyModelDefaults fills in the limits of the model not set by options
+func (a *AnthropicAgent) applyModelDefaults(info anthropic.ModelInfo) {
+	if a.requestContext.MaxTokens == 0 {
+		a.requestContext.MaxTokens = DefaultMaxTokens(info)
+	}
+	if a.contextWindow == 0 {
+		a.contextWindow = info.ContextWindow
+	}
+	if a.contextWindow == 0 {
+		a.contextWindow = DEFAULT_CONTEXT_WINDOW
+	}
+}
+
+// validateModel checks the request only uses features the model supports,
+// and that the settings of its tools are valid
+func validateModel(req *anthropic.AnthropicMessagesRequest, info anthropic.ModelInfo) error {
+	if req.MaxTokens <= 0 {
+		return fmt.Errorf("max_tokens must be positive, got %v", req.MaxTokens)
+	}
+	if info.MaxOutputTokens > 0 && req.MaxTokens > info.MaxOutputTokens {
+		return fmt.Errorf("max_tokens of %v exceeds the %v output token limit of %v", req.MaxTokens, info.MaxOutputTokens, req.Model)
+	}
+	if req.Thinking != nil && !info.Thinking {
+		return fmt.Errorf("Model %v does not support extended thinking", req.Model)
+	}
+	if slices.Contains(req.Betas, anthropic.BETA_INTERLEAVED_THINKING) && !info.InterleavedThinking {
+		return fmt.Errorf("Model %v does not support interleaved thinking", req.Model)
+	}
+	for _, tool := range req.Tools {
+		if v, ok := tool.(anthropic.ValidatingTool); ok {
+			if err := v.Validate(); err != nil {
+				return err
+			}
+		}
+		if !info.SupportsToolType(tool.GetType()) {
+			return fmt.Errorf("Model %v does not support tool '%v' of type %v", req.Model, tool.GetName(), tool.GetType())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package agents
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frozenkro/go-agent/clients"
+	"github.com/frozenkro/go-agent/clients/replay"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// replayClient returns a client that answers from testdata/<name>.json. Set
+// GA_REPLAY_MODE=record with an API key to re-record the fixture.
+func replayClient(t *testing.T, name string) (*clients.AnthropicClient, *replay.Transport) {
+	t.Helper()
+	mode := replay.ModeFromEnv()
+	transport, err := replay.NewTransport(filepath.Join("testdata", name+".json"), mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := []clients.AnthropicClientOption{clients.WithTransport(transport), clients.WithMaxRetries(0)}
+	if mode == replay.MODE_REPLAY {
+		opts = append(opts, clients.WithApiKey("replay"))
+	}
+	return clients.NewAnthropicClient(opts...), transport
+}
+
+func TestRunToolLoopReplay(t *testing.T) {
+	client, transport := replayClient(t, "bash_tool_loop")
+
+	agent, err := NewAnthropicAgent(anthropic.SONNET_4, "Run `echo replay-ok` with the bash tool and tell me what it printed.",
+		WithTools(anthropic.BASH),
+		WithMaxTokens(1024),
+		WithClient(client),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+
+	result, err := agent.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// prompt, assistant tool_use, user tool_result, final assistant message
+	if len(result.Messages) != 4 {
+		t.Fatalf("Expected 4 messages, got %v", len(result.Messages))
+	}
+	toolUse, ok := result.Messages[1].Content[len(result.Messages[1].Content)-1].(*anthropic.ToolUseContent)
+	if !ok || toolUse.Name != anthropic.BASH {
+		t.Fatalf("Expected a bash tool_use, got %#v", result.Messages[1].Content)
+	}
+	toolResult, ok := result.Messages[2].Content[0].(anthropic.ToolResultContent)
+	if !ok || toolResult.ToolUseId != toolUse.Id || !strings.Contains(toolResult.Text(), "replay-ok") {
+		t.Fatalf("Expected the bash output for %v, got %#v", toolUse.Id, result.Messages[2].Content)
+	}
+	if result.Response.StopReason != anthropic.SR_END_TURN || !strings.Contains(result.Text, "replay-ok") {
+		t.Fatalf("Unexpected final response %v: %q", result.Response.StopReason, result.Text)
+	}
+	if unused := transport.Unused(); len(unused) > 0 {
+		t.Fatalf("%v recorded interactions were not replayed", len(unused))
+	}
+}
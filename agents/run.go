@@ -0,0 +1,107 @@
+package agents
+
+import (
+	"context"
+	"strings"
+
+	"github.com/frozenkro/go-agent/clients"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// MessagesClient sends requests to the Messages API. It is implemented by
+// clients.AnthropicClient.
+type MessagesClient interface {
+	PostMessage(context.Context, *anthropic.AnthropicMessagesRequest) (*anthropic.MessagesResponse, error)
+}
+
+type RunResult struct {
+	// Text of the final assistant message
+	Text string
+	// Citations maps parts of Text to the document spans they are based on
+	Citations []CitedSpan
+	// OutputFiles are the ids of files written by server-side code execution
+	OutputFiles []string
+	Response    *anthropic.MessagesResponse
+	Messages    []anthropic.Message
+}
+
+// WithClient sets the client used by Run. Defaults to an AnthropicClient
+// configured from the environment.
+func WithClient(client MessagesClient) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.client = client
+	}
+}
+
+// Run sends the conversation to the API, running requested tools and sending
+// their results back, until the model ends its turn. Before each request the
+// conversation is compacted if it would not fit in the context window.
+func (a *AnthropicAgent) Run(ctx context.Context) (*RunResult, error) {
+	if a.client == nil {
+		a.client = clients.NewAnthropicClient()
+	}
+
+	request := a.GetRequest()
+	for {
+		if err := a.fitContext(ctx, request); err != nil {
+			return nil, err
+		}
+		response, err := a.client.PostMessage(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		var done bool
+		request, done, err = a.HandleResponse(response)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			// After pause_turn the final message holds several responses
+			final := request.Messages[len(request.Messages)-1]
+			text, citations := responseText(final.Content)
+			return &RunResult{
+				Text:        text,
+				Citations:   citations,
+				OutputFiles: outputFiles(request.Messages),
+				Response:    response,
+				Messages:    request.Messages,
+			}, nil
+		}
+	}
+}
+
+// responseText joins the text blocks of a message. With citations the API
+// splits sentences across blocks, so blocks are joined without separators.
+func responseText(content []anthropic.Content) (string, []CitedSpan) {
+	var b strings.Builder
+	spans := []CitedSpan{}
+	for _, c := range content {
+		text, ok := c.(*anthropic.TextContent)
+		if !ok {
+			continue
+		}
+		if len(text.Citations) > 0 {
+			spans = append(spans, CitedSpan{
+				Start:     b.Len(),
+				End:       b.Len() + len(text.Text),
+				Text:      text.Text,
+				Citations: text.Citations,
+			})
+		}
+		b.WriteString(text.Text)
+	}
+	return b.String(), spans
+}
+
+func outputFiles(messages []anthropic.Message) []string {
+	ids := []string{}
+	for _, m := range messages {
+		for _, c := range m.Content {
+			if result, ok := c.(*anthropic.CodeExecutionToolResultContent); ok {
+				ids = append(ids, result.FileIds()...)
+			}
+		}
+	}
+	return ids
+}
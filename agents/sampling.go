@@ -0,0 +1,96 @@
+package agents
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// Lowest top_p accepted together with extended thinking
+const MIN_THINKING_TOP_P float64 = 0.95
+
+// WithTemperature sets the randomness of responses, from 0 to 1. It cannot
+// be combined with extended thinking.
+func WithTemperature(temperature float64) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.requestContext.Temperature = &temperature
+	}
+}
+
+// WithTopK samples only from the k most likely tokens
+func WithTopK(k int) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.requestContext.TopK = k
+	}
+}
+
+// WithTopP samples from the most likely tokens whose probabilities add up to
+// p, from 0 to 1
+func WithTopP(p float64) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.requestContext.TopP = &p
+	}
+}
+
+// WithStopSequences ends a response when the model generates any of
+// sequences
+func WithStopSequences(sequences ...string) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.requestContext.StopSequences = append(a.requestContext.StopSequences, sequences...)
+	}
+}
+
+// WithUserId identifies the end user in the request metadata. Use an opaque
+// id such as a hash, never a name or email address.
+func WithUserId(userId string) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.requestContext.Metadata = &anthropic.Metadata{UserId: userId}
+	}
+}
+
+// WithServiceTier chooses whether requests may use priority capacity
+func WithServiceTier(tier anthropic.ServiceTier) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.requestContext.ServiceTier = tier
+	}
+}
+
+func validateSampling(req *anthropic.AnthropicMessagesRequest) error {
+	if t := req.Temperature; t != nil {
+		if *t < 0 || *t > 1 {
+			return fmt.Errorf("Temperature must be between 0 and 1, got %v", *t)
+		}
+		if req.Thinking != nil {
+			return fmt.Errorf("Temperature cannot be set with extended thinking")
+		}
+	}
+	if p := req.TopP; p != nil {
+		if *p < 0 || *p > 1 {
+			return fmt.Errorf("top_p must be between 0 and 1, got %v", *p)
+		}
+		if req.Thinking != nil && *p < MIN_THINKING_TOP_P {
+			return fmt.Errorf("top_p must be at least %v with extended thinking, got %v", MIN_THINKING_TOP_P, *p)
+		}
+	}
+	if req.TopK < 0 {
+		return fmt.Errorf("top_k must be positive, got %v", req.TopK)
+	}
+	if req.TopK > 0 && req.Thinking != nil {
+		return fmt.Errorf("top_k cannot be set with extended thinking")
+	}
+	for _, sequence := range req.StopSequences {
+		if strings.TrimSpace(sequence) == "" {
+			return fmt.Errorf("Stop sequences must contain non-whitespace characters, got %q", sequence)
+		}
+	}
+	if req.Metadata != nil && req.Metadata.UserId == "" {
+		return fmt.Errorf("Metadata user_id cannot be empty")
+	}
+	switch req.ServiceTier {
+	case "", anthropic.SERVICE_TIER_AUTO, anthropic.SERVICE_TIER_STANDARD_ONLY:
+	default:
+		return fmt.Errorf("Unknown service tier '%v'", req.ServiceTier)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package agents
+
+import (
+	"testing"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func TestValidateSampling(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []AnthropicAgentOption
+		err  bool
+	}{
+		{name: "defaults"},
+		{name: "temperature", opts: []AnthropicAgentOption{WithTemperature(0.2)}},
+		{name: "temperature bounds", opts: []AnthropicAgentOption{WithTemperature(0), WithTopP(1)}},
+		{name: "temperature above 1", opts: []AnthropicAgentOption{WithTemperature(1.5)}, err: true},
+		{name: "negative temperature", opts: []AnthropicAgentOption{WithTemperature(-0.1)}, err: true},
+		{name: "temperature with thinking", opts: []AnthropicAgentOption{WithThinking(2048), WithTemperature(0.5)}, err: true},
+		{name: "top_p", opts: []AnthropicAgentOption{WithTopP(0.9)}},
+		{name: "top_p above 1", opts: []AnthropicAgentOption{WithTopP(1.1)}, err: true},
+		{name: "top_p with thinking", opts: []AnthropicAgentOption{WithThinking(2048), WithTopP(0.95)}},
+		{name: "low top_p with thinking", opts: []AnthropicAgentOption{WithThinking(2048), WithTopP(0.9)}, err: true},
+		{name: "top_k", opts: []AnthropicAgentOption{WithTopK(40)}},
+		{name: "negative top_k", opts: []AnthropicAgentOption{WithTopK(-1)}, err: true},
+		{name: "top_k with thinking", opts: []AnthropicAgentOption{WithThinking(2048), WithTopK(40)}, err: true},
+		{name: "stop sequences", opts: []AnthropicAgentOption{WithStopSequences("END", " STOP ")}},
+		{name: "blank stop sequence", opts: []AnthropicAgentOption{WithStopSequences("END", " \n")}, err: true},
+		{name: "user id", opts: []AnthropicAgentOption{WithUserId("4f1c2a")}},
+		{name: "empty user id", opts: []AnthropicAgentOption{WithUserId("")}, err: true},
+		{name: "auto service tier", opts: []AnthropicAgentOption{WithServiceTier(anthropic.SERVICE_TIER_AUTO)}},
+		{name: "standard only service tier", opts: []AnthropicAgentOption{WithServiceTier(anthropic.SERVICE_TIER_STANDARD_ONLY)}},
+		{name: "unknown service tier", opts: []AnthropicAgentOption{WithServiceTier("priority")}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, err := NewAnthropicAgent(anthropic.SONNET_4, "Hello", tt.opts...)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if err == nil {
+				agent.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/frozenkro/go-agent/clients"
+	"github.com/frozenkro/go-agent/clients/anthropictest"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func testClient(srv *anthropictest.Server) *clients.AnthropicClient {
+	return clients.NewAnthropicClient(clients.WithBaseUrl(srv.URL), clients.WithApiKey("test"), clients.WithMaxRetries(0))
+}
+
+func runAgent(t *testing.T, srv *anthropictest.Server, opts ...AnthropicAgentOption) (*RunResult, []Event, error) {
+	t.Helper()
+	opts = append(opts, WithClient(testClient(srv)))
+	agent, err := NewAnthropicAgent(anthropic.SONNET_4, "Search for the Go release notes", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe := agent.Subscribe()
+
+	result, err := agent.Run(context.Background())
+	unsubscribe()
+	agent.Close()
+
+	received := []Event{}
+	for e := range events {
+		received = append(received, e)
+	}
+	return result, received, err
+}
+
+func TestServerToolUseIsNotInvoked(t *testing.T) {
+	srv := anthropictest.NewServer(
+		anthropictest.Reply(anthropic.SR_TOOL_USE,
+			anthropictest.ServerToolUse("srvtoolu_1", anthropic.WEB_SEARCH, map[string]any{"query": "go release notes"}),
+			anthropictest.WebSearchResult("srvtoolu_1", "https://go.dev/doc/devel/release"),
+			anthropictest.ToolUse("toolu_1", anthropic.BASH, map[string]any{"command": "echo local"}),
+		),
+		anthropictest.Reply(anthropic.SR_END_TURN, anthropictest.Text("done")).Expect(func(r *anthropictest.Request) error {
+			results := r.Messages[len(r.Messages)-1].Content
+			if len(results) != 1 {
+				return fmt.Errorf("Expected a single tool_result, got %v", len(results))
+			}
+			return nil
+		}),
+	)
+	defer srv.Close()
+
+	_, events, err := runAgent(t, srv, WithTools(anthropic.BASH), WithServerTools(anthropic.NewWebSearchTool()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		if e.Type == EVENT_TOOL_START && e.ToolName != anthropic.BASH {
+			t.Fatalf("Server tool %v was invoked locally", e.ToolName)
+		}
+	}
+}
+
+func TestPauseTurnResumes(t *testing.T) {
+	srv := anthropictest.NewServer(
+		anthropictest.Reply(anthropic.SR_PAUSE_TURN,
+			anthropictest.ServerToolUse("srvtoolu_1", anthropic.WEB_SEARCH, map[string]any{"query": "go release notes"}),
+		),
+		anthropictest.Reply(anthropic.SR_END_TURN,
+			anthropictest.WebSearchResult("srvtoolu_1", "https://go.dev/doc/devel/release"),
+			anthropictest.Text("Go 1.25 is out."),
+		).Expect(func(r *anthropictest.Request) error {
+			last := r.Messages[len(r.Messages)-1]
+			if last.Role != anthropic.ASSISTANT || len(last.Content) != 1 || last.Content[0].GetType() != anthropic.SERVER_TOOL_USE {
+				return fmt.Errorf("Expected the paused assistant message to be sent back as is, got %v %v", last.Role, len(last.Content))
+			}
+			return nil
+		}),
+	)
+	defer srv.Close()
+
+	result, _, err := runAgent(t, srv, WithServerTools(anthropic.NewWebSearchTool()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Messages) != 2 || len(result.Messages[1].Content) != 3 {
+		t.Fatalf("Expected the continuation to extend the paused message, got %v messages", len(result.Messages))
+	}
+	if result.Text != "Go 1.25 is out." {
+		t.Fatalf("Unexpected text %q", result.Text)
+	}
+}
+
+func TestPauseTurnLimit(t *testing.T) {
+	steps := []anthropictest.Step{}
+	for i := 0; i <= 2; i++ {
+		steps = append(steps, anthropictest.Reply(anthropic.SR_PAUSE_TURN,
+			anthropictest.ServerToolUse(fmt.Sprintf("srvtoolu_%v", i), anthropic.WEB_SEARCH, map[string]any{"query": "go"})))
+	}
+	srv := anthropictest.NewServer(steps...)
+	defer srv.Close()
+
+	_, _, err := runAgent(t, srv, WithServerTools(anthropic.NewWebSearchTool()), WithMaxPauses(2))
+	if err == nil || !strings.Contains(err.Error(), "paused more than 2 times") {
+		t.Fatalf("Expected the pause limit to end the run, got %v", err)
+	}
+}
+
+func TestServerToolDomainsValidated(t *testing.T) {
+	search := anthropic.NewWebSearchTool()
+	search.AllowedDomains = []string{"go.dev"}
+	search.BlockedDomains = []string{"example.com"}
+
+	_, err := NewAnthropicAgent(anthropic.SONNET_4, "hi", WithServerTools(search))
+	if err == nil {
+		t.Fatal("Expected an error for both allowed_domains and blocked_domains")
+	}
+}
@@ -0,0 +1,226 @@
+package agents
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// PROJECT_INSTRUCTIONS_FILE holds instructions for agents working in a
+// project, found in the working directory or any directory above it
+const PROJECT_INSTRUCTIONS_FILE string = "AGENTS.md"
+
+// PromptData is available to system prompt templates, e.g. {{.Cwd}}
+type PromptData struct {
+	Cwd   string
+	OS    string
+	Arch  string
+	Shell string
+	// Date is today's date as YYYY-MM-DD
+	Date  string
+	Model anthropic.Model
+	Tools []anthropic.ToolName
+}
+
+// systemPart is a block of the system prompt, read from path when set and
+// rendered as a template when template is set
+type systemPart struct {
+	block    anthropic.TextContent
+	path     string
+	template bool
+}
+
+// WithSystemPrompt adds a block of text to the system prompt
+func WithSystemPrompt(text string) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.system = append(a.system, systemPart{block: *anthropic.NewTextContent(text)})
+	}
+}
+
+// WithSystemBlocks adds blocks to the system prompt as given, e.g. with
+// their own cache_control
+func WithSystemBlocks(blocks ...anthropic.TextContent) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		for _, block := range blocks {
+			a.system = append(a.system, systemPart{block: block})
+		}
+	}
+}
+
+// WithSystemPromptTemplate adds a block to the system prompt rendered with
+// text/template and PromptData
+func WithSystemPromptTemplate(text string) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.system = append(a.system, systemPart{block: *anthropic.NewTextContent(text), template: true})
+	}
+}
+
+// WithSystemPromptFile adds the contents of a file to the system prompt,
+// rendered as a template like WithSystemPromptTemplate
+func WithSystemPromptFile(path string) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.system = append(a.system, systemPart{block: *anthropic.NewTextContent(""), path: path, template: true})
+	}
+}
+
+// WithProjectInstructions adds every PROJECT_INSTRUCTIONS_FILE found in dir,
+// or the working directory when dir is empty, and the directories above it
+// up to the repository root. Files closer to dir come later.
+func WithProjectInstructions(dir string) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.projectDir = &dir
+	}
+}
+
+// WithSystemPromptCache caches the system prompt, and the tools before it,
+// by adding a cache breakpoint to its last block
+func WithSystemPromptCache(ttl anthropic.CacheTTL) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.systemCache = ttl
+	}
+}
+
+// buildSystemPrompt reads and renders the parts of the system prompt
+func (a *AnthropicAgent) buildSystemPrompt() (anthropic.SystemPrompt, error) {
+	if len(a.system) == 0 && a.projectDir == nil {
+		return nil, nil
+	}
+
+	data, err := a.promptData()
+	if err != nil {
+		return nil, err
+	}
+
+	prompt := anthropic.SystemPrompt{}
+	for _, part := range a.system {
+		block := part.block
+		if part.path != "" {
+			content, err := os.ReadFile(part.path)
+			if err != nil {
+				return nil, err
+			}
+			block.Text = string(content)
+		}
+		if part.template {
+			block.Text, err = renderPrompt(block.Text, data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		// The API rejects empty text blocks, e.g. from an empty file
+		if strings.TrimSpace(block.Text) == "" {
+			continue
+		}
+		prompt = append(prompt, block)
+	}
+
+	if a.projectDir != nil {
+		dir := *a.projectDir
+		if dir == "" {
+			dir = data.Cwd
+		}
+		instructions, err := projectInstructions(dir)
+		if err != nil {
+			return nil, err
+		}
+		prompt = append(prompt, instructions...)
+	}
+
+	if len(prompt) == 0 {
+		return nil, nil
+	}
+	if a.systemCache != "" {
+		prompt = prompt.Cached(a.systemCache)
+	}
+	return prompt, nil
+}
+
+func (a *AnthropicAgent) promptData() (PromptData, error) {
+	cwd, err := a.cwd()
+	if err != nil {
+		return PromptData{}, err
+	}
+
+	tools := []anthropic.ToolName{}
+	for _, tool := range a.requestContext.Tools {
+		tools = append(tools, tool.GetName())
+	}
+	return PromptData{
+		Cwd:   cwd,
+		OS:    runtime.GOOS,
+		Arch:  runtime.GOARCH,
+		Shell: os.Getenv("SHELL"),
+		Date:  time.Now().Format(time.DateOnly),
+		Model: a.requestContext.Model,
+		Tools: tools,
+	}, nil
+}
+
+// cwd returns the absolute working directory set with WithWorkingDir, or the
+// current directory
+func (a *AnthropicAgent) cwd() (string, error) {
+	if a.workingDir == "" {
+		return os.Getwd()
+	}
+	return filepath.Abs(a.workingDir)
+}
+
+func renderPrompt(text string, data PromptData) (string, error) {
+	tmpl, err := template.New("system").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("Invalid system prompt template: %w", err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("Unable to render system prompt: %w", err)
+	}
+	return b.String(), nil
+}
+
+// projectInstructions returns a block for each instructions file from the
+// repository root, or the filesystem root outside a repository, down to dir
+func projectInstructions(dir string) ([]anthropic.TextContent, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for {
+		path := filepath.Join(dir, PROJECT_INSTRUCTIONS_FILE)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			paths = append(paths, path)
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	slices.Reverse(paths)
+
+	blocks := []anthropic.TextContent{}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(string(content)) == "" {
+			continue
+		}
+		text := fmt.Sprintf("Project instructions from %v:\n\n%v", path, strings.TrimSpace(string(content)))
+		blocks = append(blocks, *anthropic.NewTextContent(text))
+	}
+	return blocks, nil
+}
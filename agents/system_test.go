@@ -0,0 +1,134 @@
+package agents
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProjectInstructions(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, PROJECT_INSTRUCTIONS_FILE), "outside")
+	writeFile(t, filepath.Join(root, "repo", ".git", "HEAD"), "ref: refs/heads/main")
+	writeFile(t, filepath.Join(root, "repo", PROJECT_INSTRUCTIONS_FILE), "repo")
+	writeFile(t, filepath.Join(root, "repo", "pkg", PROJECT_INSTRUCTIONS_FILE), "pkg")
+	writeFile(t, filepath.Join(root, "repo", "pkg", "empty", PROJECT_INSTRUCTIONS_FILE), "\n")
+	// A worktree has a .git file rather than a directory
+	writeFile(t, filepath.Join(root, "worktree", ".git"), "gitdir: ../repo/.git")
+	writeFile(t, filepath.Join(root, "worktree", "cmd", PROJECT_INSTRUCTIONS_FILE), "cmd")
+	// A directory named AGENTS.md is not an instructions file
+	if err := os.MkdirAll(filepath.Join(root, "repo", "docs", PROJECT_INSTRUCTIONS_FILE), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		want []string
+		// Without a repository every directory up to the filesystem root is searched
+		outsideRepo bool
+	}{
+		{"repository root", "repo", []string{"repo"}, false},
+		{"nested directory", "repo/pkg/empty", []string{"repo", "pkg"}, false},
+		{"directory without instructions", "repo/docs", []string{"repo"}, false},
+		{"worktree", "worktree/cmd", []string{"cmd"}, false},
+		{"outside a repository", "plain", []string{"outside"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(root, tt.dir)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			blocks, err := projectInstructions(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, block := range blocks {
+				_, text, _ := strings.Cut(block.Text, ":\n\n")
+				got = append(got, text)
+			}
+			// Files above the temporary directory are not part of the test
+			if tt.outsideRepo && len(got) > len(tt.want) {
+				got = got[len(got)-len(tt.want):]
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBuildSystemPrompt(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ".git", "HEAD"), "ref: refs/heads/main")
+	writeFile(t, filepath.Join(dir, PROJECT_INSTRUCTIONS_FILE), "Run go vet")
+	writeFile(t, filepath.Join(dir, "prompt.md"), "Working in {{.Cwd}}")
+	writeFile(t, filepath.Join(dir, "empty.md"), "")
+	cached := *anthropic.NewTextContent("Cached block")
+
+	tests := []struct {
+		name  string
+		opts  []AnthropicAgentOption
+		want  []string
+		cache bool
+		err   string
+	}{
+		{name: "no system prompt"},
+		{name: "text", opts: []AnthropicAgentOption{WithSystemPrompt("Be brief")}, want: []string{"Be brief"}},
+		{name: "template", opts: []AnthropicAgentOption{WithSystemPromptTemplate("Model {{.Model}}")}, want: []string{"Model " + string(anthropic.SONNET_4)}},
+		{name: "file", opts: []AnthropicAgentOption{WithSystemPromptFile(filepath.Join(dir, "prompt.md"))}, want: []string{"Working in " + dir}},
+		{name: "empty blocks are dropped", opts: []AnthropicAgentOption{WithSystemPrompt(" "), WithSystemPromptFile(filepath.Join(dir, "empty.md"))}},
+		{name: "project instructions", opts: []AnthropicAgentOption{WithSystemPrompt("Be brief"), WithProjectInstructions("")}, want: []string{"Be brief", "Project instructions from " + filepath.Join(dir, PROJECT_INSTRUCTIONS_FILE) + ":\n\nRun go vet"}},
+		{name: "cached", opts: []AnthropicAgentOption{WithSystemBlocks(cached), WithSystemPromptCache(anthropic.TTL_5m)}, want: []string{"Cached block"}, cache: true},
+		{name: "missing template key", opts: []AnthropicAgentOption{WithSystemPromptTemplate("{{.Missing}}")}, err: "Unable to render system prompt"},
+		{name: "invalid template", opts: []AnthropicAgentOption{WithSystemPromptTemplate("{{.Cwd")}, err: "Invalid system prompt template"},
+		{name: "missing file", opts: []AnthropicAgentOption{WithSystemPromptFile(filepath.Join(dir, "missing.md"))}, err: "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]AnthropicAgentOption{WithWorkingDir(dir)}, tt.opts...)
+			agent, err := NewAnthropicAgent(anthropic.SONNET_4, "Hello", opts...)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer agent.Close()
+
+			system := agent.GetRequest().System
+			if tt.want == nil && system != nil {
+				t.Fatalf("Expected no system prompt, got %+v", system)
+			}
+			got := []string{}
+			for _, block := range system {
+				got = append(got, block.Text)
+			}
+			if len(tt.want) > 0 && !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+			if cached := len(system) > 0 && system[len(system)-1].CacheControl != nil; cached != tt.cache {
+				t.Errorf("Expected cache_control %v, got %v", tt.cache, cached)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package agents
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// WithThinking enables extended thinking with a budget of budgetTokens. The
+// budget must be at least anthropic.MIN_THINKING_BUDGET and, unless
+// interleaved thinking is enabled, less than max_tokens.
+func WithThinking(budgetTokens int) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.requestContext.Thinking = &anthropic.ThinkingData{
+			Type:         anthropic.THINKING_ENABLED,
+			BudgetTokens: budgetTokens,
+		}
+	}
+}
+
+// WithInterleavedThinking lets the model think between tool calls instead of
+// only at the start of its turn. The thinking budget then applies to the
+// whole turn and may exceed max_tokens.
+func WithInterleavedThinking() AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		if !slices.Contains(a.requestContext.Betas, anthropic.BETA_INTERLEAVED_THINKING) {
+			a.requestContext.Betas = append(a.requestContext.Betas, anthropic.BETA_INTERLEAVED_THINKING)
+		}
+	}
+}
+
+func validateThinking(req *anthropic.AnthropicMessagesRequest) error {
+	if req.Thinking == nil {
+		return nil
+	}
+
+	budget := req.Thinking.BudgetTokens
+	if budget < anthropic.MIN_THINKING_BUDGET {
+		return fmt.Errorf("Thinking budget of %v tokens is below the minimum of %v", budget, anthropic.MIN_THINKING_BUDGET)
+	}
+	if budget >= req.MaxTokens && !slices.Contains(req.Betas, anthropic.BETA_INTERLEAVED_THINKING) {
+		return fmt.Errorf("Thinking budget of %v tokens must be less than max_tokens (%v)", budget, req.MaxTokens)
+	}
+	return nil
+}
+
+// thinking publishes the thinking blocks of a response. Redacted thinking is
+// published with empty Data, as its content is encrypted.
+func (b *eventBus) thinking(content []anthropic.Content) {
+	for _, c := range content {
+		switch t := c.(type) {
+		case *anthropic.ThinkingContent:
+			b.publish(Event{Type: EVENT_THINKING, Data: t.Thinking})
+		case *anthropic.RedactedThinkingContent:
+			b.publish(Event{Type: EVENT_THINKING})
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package agents
+
+import (
+	"fmt"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// WithToolChoice sets the tool_choice of every turn without an override,
+// either anthropic.TOOL_CHOICE_AUTO or anthropic.TOOL_CHOICE_NONE, as forcing
+// tool use on every turn would keep the model from ending its turn. Defaults
+// to letting the API decide, which is equivalent to TOOL_CHOICE_AUTO.
+func WithToolChoice(choice anthropic.ToolChoice) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.toolChoice = &choice
+	}
+}
+
+// WithDisableParallelToolUse limits the model to at most one tool call per
+// response, or exactly one when a tool use is forced
+func WithDisableParallelToolUse() AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		a.disableParallelToolUse = true
+	}
+}
+
+// WithTurnToolChoice overrides the tool_choice of a single turn, counted
+// from 0 for the first request. Later turns revert to the default.
+func WithTurnToolChoice(turn int, choice anthropic.ToolChoice) AnthropicAgentOption {
+	return func(a *AnthropicAgent) {
+		if a.turnToolChoices == nil {
+			a.turnToolChoices = map[int]anthropic.ToolChoice{}
+		}
+		a.turnToolChoices[turn] = choice
+	}
+}
+
+// WithForcedTool makes the model call the tool named name on the first turn,
+// e.g. to extract structured output, and leaves later turns to the default
+func WithForcedTool(name anthropic.ToolName) AnthropicAgentOption {
+	return WithTurnToolChoice(0, anthropic.NewToolChoiceTool(name))
+}
+
+// SetNextToolChoice overrides the tool_choice of the next request only
+func (a *AnthropicAgent) SetNextToolChoice(choice anthropic.ToolChoice) error {
+	if err := validateToolChoice(a.requestContext, choice); err != nil {
+		return err
+	}
+	if a.turnToolChoices == nil {
+		a.turnToolChoices = map[int]anthropic.ToolChoice{}
+	}
+	a.turnToolChoices[a.turn] = choice
+	a.applyToolChoice()
+	return nil
+}
+
+// applyToolChoice sets the tool_choice of the request for the current turn
+func (a *AnthropicAgent) applyToolChoice() {
+	var choice *anthropic.ToolChoice
+	if override, ok := a.turnToolChoices[a.turn]; ok {
+		choice = &override
+	} else if a.toolChoice != nil {
+		c := *a.toolChoice
+		choice = &c
+	}
+
+	if a.disableParallelToolUse {
+		if choice == nil {
+			c := anthropic.NewToolChoice(anthropic.TOOL_CHOICE_AUTO)
+			choice = &c
+		}
+		if choice.Type != anthropic.TOOL_CHOICE_NONE {
+			choice.DisableParallelToolUse = true
+		}
+	}
+	a.requestContext.ToolChoice = choice
+}
+
+func (a *AnthropicAgent) validateToolChoices() error {
+	if a.toolChoice != nil {
+		if err := validateToolChoice(a.requestContext, *a.toolChoice); err != nil {
+			return err
+		}
+		// The model could never end its turn
+		if a.toolChoice.Forces() {
+			return fmt.Errorf("tool_choice '%v' cannot be the default for every turn. Force it for single turns with WithTurnToolChoice or WithForcedTool", a.toolChoice.Type)
+		}
+	}
+	for turn, choice := range a.turnToolChoices {
+		if err := validateToolChoice(a.requestContext, choice); err != nil {
+			return fmt.Errorf("Turn %v: %w", turn, err)
+		}
+	}
+	return nil
+}
+
+func validateToolChoice(req *anthropic.AnthropicMessagesRequest, choice anthropic.ToolChoice) error {
+	switch choice.Type {
+	case anthropic.TOOL_CHOICE_AUTO, anthropic.TOOL_CHOICE_NONE:
+	case anthropic.TOOL_CHOICE_ANY:
+		if len(req.Tools) == 0 {
+			return fmt.Errorf("tool_choice 'any' requires at least one tool")
+		}
+	case anthropic.TOOL_CHOICE_TOOL:
+		if !hasTool(req, choice.Name) {
+			return fmt.Errorf("tool_choice forces tool '%v', which is not registered", choice.Name)
+		}
+	default:
+		return fmt.Errorf("Unknown tool_choice type '%v'", choice.Type)
+	}
+
+	if choice.Type != anthropic.TOOL_CHOICE_TOOL && choice.Name != "" {
+		return fmt.Errorf("tool_choice '%v' does not take a tool name", choice.Type)
+	}
+	if choice.Forces() && req.Thinking != nil {
+		return fmt.Errorf("Extended thinking only supports tool_choice 'auto' and 'none', got '%v'", choice.Type)
+	}
+	return nil
+}
+
+func hasTool(req *anthropic.AnthropicMessagesRequest, name anthropic.ToolName) bool {
+	for _, tool := range req.Tools {
+		if tool.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
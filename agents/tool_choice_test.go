@@ -0,0 +1,105 @@
+package agents
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func TestToolChoiceOptions(t *testing.T) {
+	choice := func(choiceType anthropic.ToolChoiceType, name anthropic.ToolName, disableParallel bool) *anthropic.ToolChoice {
+		return &anthropic.ToolChoice{Type: choiceType, Name: name, DisableParallelToolUse: disableParallel}
+	}
+
+	tests := []struct {
+		name string
+		opts []AnthropicAgentOption
+		// first and second are the tool_choice of the first two turns
+		first  *anthropic.ToolChoice
+		second *anthropic.ToolChoice
+		err    bool
+	}{
+		{name: "api default", opts: []AnthropicAgentOption{WithTools(anthropic.BASH)}},
+		{
+			name:   "auto",
+			opts:   []AnthropicAgentOption{WithTools(anthropic.BASH), WithToolChoice(anthropic.NewToolChoice(anthropic.TOOL_CHOICE_AUTO))},
+			first:  choice(anthropic.TOOL_CHOICE_AUTO, "", false),
+			second: choice(anthropic.TOOL_CHOICE_AUTO, "", false),
+		},
+		{
+			name:   "parallel tool use disabled",
+			opts:   []AnthropicAgentOption{WithTools(anthropic.BASH), WithDisableParallelToolUse()},
+			first:  choice(anthropic.TOOL_CHOICE_AUTO, "", true),
+			second: choice(anthropic.TOOL_CHOICE_AUTO, "", true),
+		},
+		{
+			name:   "none ignores disabled parallel tool use",
+			opts:   []AnthropicAgentOption{WithTools(anthropic.BASH), WithToolChoice(anthropic.NewToolChoice(anthropic.TOOL_CHOICE_NONE)), WithDisableParallelToolUse()},
+			first:  choice(anthropic.TOOL_CHOICE_NONE, "", false),
+			second: choice(anthropic.TOOL_CHOICE_NONE, "", false),
+		},
+		{
+			name:  "forced tool on the first turn",
+			opts:  []AnthropicAgentOption{WithTools(anthropic.BASH), WithForcedTool(anthropic.BASH)},
+			first: choice(anthropic.TOOL_CHOICE_TOOL, anthropic.BASH, false),
+		},
+		{
+			name:   "forced tool with a default",
+			opts:   []AnthropicAgentOption{WithTools(anthropic.BASH), WithForcedTool(anthropic.BASH), WithToolChoice(anthropic.NewToolChoice(anthropic.TOOL_CHOICE_NONE)), WithDisableParallelToolUse()},
+			first:  choice(anthropic.TOOL_CHOICE_TOOL, anthropic.BASH, true),
+			second: choice(anthropic.TOOL_CHOICE_NONE, "", false),
+		},
+		{
+			name:   "any on a later turn",
+			opts:   []AnthropicAgentOption{WithTools(anthropic.BASH), WithTurnToolChoice(1, anthropic.NewToolChoice(anthropic.TOOL_CHOICE_ANY))},
+			second: choice(anthropic.TOOL_CHOICE_ANY, "", false),
+		},
+		{name: "any as the default", opts: []AnthropicAgentOption{WithTools(anthropic.BASH), WithToolChoice(anthropic.NewToolChoice(anthropic.TOOL_CHOICE_ANY))}, err: true},
+		{name: "tool as the default", opts: []AnthropicAgentOption{WithTools(anthropic.BASH), WithToolChoice(anthropic.NewToolChoiceTool(anthropic.BASH))}, err: true},
+		{name: "forced tool without a name", opts: []AnthropicAgentOption{WithTools(anthropic.BASH), WithForcedTool("")}, err: true},
+		{name: "forced tool not registered", opts: []AnthropicAgentOption{WithTools(anthropic.BASH), WithForcedTool(anthropic.TEXT_EDITOR)}, err: true},
+		{name: "any without any tool", opts: []AnthropicAgentOption{WithTurnToolChoice(0, anthropic.NewToolChoice(anthropic.TOOL_CHOICE_ANY))}, err: true},
+		{name: "name on auto", opts: []AnthropicAgentOption{WithTools(anthropic.BASH), WithToolChoice(anthropic.ToolChoice{Type: anthropic.TOOL_CHOICE_AUTO, Name: anthropic.BASH})}, err: true},
+		{name: "unknown type", opts: []AnthropicAgentOption{WithToolChoice(anthropic.NewToolChoice("sometimes"))}, err: true},
+		{name: "forced tool with thinking", opts: []AnthropicAgentOption{WithTools(anthropic.BASH), WithThinking(2048), WithForcedTool(anthropic.BASH)}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, err := NewAnthropicAgent(anthropic.SONNET_4, "Hello", tt.opts...)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			defer agent.Close()
+
+			for turn, want := range []*anthropic.ToolChoice{tt.first, tt.second} {
+				agent.turn = turn
+				agent.applyToolChoice()
+				if got := agent.GetRequest().ToolChoice; !reflect.DeepEqual(got, want) {
+					t.Errorf("Turn %v: expected %+v, got %+v", turn, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSetNextToolChoice(t *testing.T) {
+	agent, err := NewAnthropicAgent(anthropic.SONNET_4, "Hello", WithTools(anthropic.BASH))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+
+	if err := agent.SetNextToolChoice(anthropic.NewToolChoiceTool(anthropic.TEXT_EDITOR)); err == nil {
+		t.Errorf("Expected forcing an unregistered tool to fail")
+	}
+	if err := agent.SetNextToolChoice(anthropic.NewToolChoiceTool(anthropic.BASH)); err != nil {
+		t.Fatal(err)
+	}
+	if got := agent.GetRequest().ToolChoice; got == nil || got.Name != anthropic.BASH {
+		t.Errorf("Expected the next request to force bash, got %+v", got)
+	}
+}
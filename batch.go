@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/frozenkro/go-agent/clients"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+const BATCH_USAGE = `Usage: go-agent batch <command>
+
+Commands:
+  submit -file prompts.jsonl   Submit one request per line of the file
+  status <batch-id>            Show processing status and request counts
+  results <batch-id>           Print results as JSON lines
+  cancel <batch-id>            Cancel processing
+
+Each line of the prompts file is either {"custom_id": "...", "prompt": "..."}
+or {"custom_id": "...", "params": {...}} with full Messages API parameters.
+Params without a model or max_tokens use the defaults given to submit. Tools
+in params are sent as given, but betas they need cannot be set.`
+
+// batchPrompt is a line of the prompts file given to batch submit
+type batchPrompt struct {
+	CustomId string                              `json:"custom_id"`
+	Prompt   string                              `json:"prompt"`
+	Params   *anthropic.AnthropicMessagesRequest `json:"params"`
+}
+
+// batchOutput is a line printed by batch results
+type batchOutput struct {
+	CustomId string                    `json:"custom_id"`
+	Type     anthropic.BatchResultType `json:"type"`
+	Text     string                    `json:"text,omitempty"`
+	Error    string                    `json:"error,omitempty"`
+}
+
+func runBatch(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%v", BATCH_USAGE)
+	}
+
+	ctx := context.Background()
+	client := clients.NewAnthropicClient()
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "submit":
+		return submitBatch(ctx, client, args, os.Stdout)
+	case "status":
+		id, err := batchId(args)
+		if err != nil {
+			return err
+		}
+		batch, err := client.GetBatch(ctx, id)
+		if err != nil {
+			return err
+		}
+		printBatch(os.Stdout, batch)
+	case "results":
+		id, err := batchId(args)
+		if err != nil {
+			return err
+		}
+		return printBatchResults(ctx, client, id, os.Stdout)
+	case "cancel":
+		id, err := batchId(args)
+		if err != nil {
+			return err
+		}
+		batch, err := client.CancelBatch(ctx, id)
+		if err != nil {
+			return err
+		}
+		printBatch(os.Stdout, batch)
+	default:
+		return fmt.Errorf("Unknown batch command '%v'\n\n%v", command, BATCH_USAGE)
+	}
+	return nil
+}
+
+func batchId(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("Expected a batch id\n\n%v", BATCH_USAGE)
+	}
+	return args[0], nil
+}
+
+func submitBatch(ctx context.Context, client *clients.AnthropicClient, args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("batch submit", flag.ExitOnError)
+	file := flags.String("file", "", "JSONL file of prompts")
+	model := flags.String("model", string(anthropic.SONNET_4), "Model for prompts without params")
+	maxTokens := flags.Int("max-tokens", 0, "max_tokens for prompts without params (defaults to the model's default)")
+	system := flags.String("system", "", "System prompt for prompts without params")
+	flags.Parse(args)
+
+	if *file == "" {
+		return fmt.Errorf("batch submit requires -file")
+	}
+	info, err := lookupModel(ctx, client, anthropic.Model(*model))
+	if err != nil {
+		return err
+	}
+	defaults := anthropic.AnthropicMessagesRequest{
+		Model:     info.Model,
+		MaxTokens: maxTokensFor(info, *maxTokens),
+	}
+	if *system != "" {
+		defaults.System = anthropic.NewSystemPrompt(*system)
+	}
+	requests, err := readBatchPrompts(*file, defaults)
+	if err != nil {
+		return err
+	}
+	// Params for another model get that model's default max_tokens
+	for i := range requests {
+		params := &requests[i].Params
+		if params.MaxTokens > 0 {
+			continue
+		}
+		info, err := lookupModel(ctx, client, params.Model)
+		if err != nil {
+			return fmt.Errorf("Request '%v': %w", requests[i].CustomId, err)
+		}
+		params.MaxTokens = maxTokensFor(info, *maxTokens)
+	}
+
+	batch, err := client.CreateBatch(ctx, requests)
+	if err != nil {
+		return err
+	}
+	printBatch(w, batch)
+	return nil
+}
+
+// readBatchPrompts reads the prompts file, filling in prompts without params
+// from defaults. Params without a model get the default model, and its
+// max_tokens unless they set it.
+func readBatchPrompts(path string, defaults anthropic.AnthropicMessagesRequest) ([]anthropic.BatchRequest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	requests := []anthropic.BatchRequest{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 32*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var p batchPrompt
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			return nil, fmt.Errorf("Invalid prompt on line %v: %w", line, err)
+		}
+		if p.CustomId == "" {
+			p.CustomId = fmt.Sprintf("line-%v", line)
+		}
+
+		params := p.Params
+		if params == nil {
+			if p.Prompt == "" {
+				return nil, fmt.Errorf("Line %v has neither a prompt nor params", line)
+			}
+			request := defaults
+			request.Messages = []anthropic.Message{{
+				Role:    anthropic.USER,
+				Content: []anthropic.Content{anthropic.NewTextContent(p.Prompt)},
+			}}
+			params = &request
+		}
+		if params.Model == "" {
+			params.Model = defaults.Model
+		}
+		if params.MaxTokens == 0 && params.Model == defaults.Model {
+			params.MaxTokens = defaults.MaxTokens
+		}
+		requests = append(requests, anthropic.BatchRequest{CustomId: p.CustomId, Params: *params})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func printBatch(w io.Writer, batch *anthropic.MessageBatch) {
+	counts := batch.RequestCounts
+	fmt.Fprintf(w, "%v\t%v\tprocessing=%v succeeded=%v errored=%v canceled=%v expired=%v\n",
+		batch.Id, batch.ProcessingStatus, counts.Processing, counts.Succeeded, counts.Errored, counts.Canceled, counts.Expired)
+}
+
+func printBatchResults(ctx context.Context, client *clients.AnthropicClient, id string, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for result, err := range client.BatchResults(ctx, id) {
+		if err != nil {
+			return err
+		}
+
+		out := batchOutput{CustomId: result.CustomId, Type: result.Result.Type}
+		if message := result.Result.Message; message != nil {
+			texts := []string{}
+			for _, c := range message.Content {
+				if text, ok := c.(*anthropic.TextContent); ok {
+					texts = append(texts, text.Text)
+				}
+			}
+			out.Text = strings.Join(texts, "")
+		}
+		if e := result.Result.Error; e != nil {
+			out.Error = fmt.Sprintf("%v: %v", e.Error.Type, e.Error.Message)
+		}
+		if err := encoder.Encode(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/frozenkro/go-agent/clients"
+	"github.com/frozenkro/go-agent/clients/anthropictest"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func writePrompts(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "prompts.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadBatchPrompts(t *testing.T) {
+	defaults := anthropic.AnthropicMessagesRequest{
+		Model:     anthropic.SONNET_4,
+		MaxTokens: 1000,
+		System:    anthropic.NewSystemPrompt("Be brief"),
+	}
+
+	tests := []struct {
+		name      string
+		line      string
+		customId  string
+		model     anthropic.Model
+		maxTokens int
+		tools     []anthropic.ToolName
+		system    string
+	}{
+		{
+			name:      "prompt",
+			line:      `{"custom_id": "a", "prompt": "Hello"}`,
+			customId:  "a",
+			model:     anthropic.SONNET_4,
+			maxTokens: 1000,
+			system:    "Be brief",
+		},
+		{
+			name:      "params with tools",
+			line:      `{"custom_id": "b", "params": {"model": "claude-3-5-haiku-20241022", "max_tokens": 50, "messages": [{"role": "user", "content": "Hi"}], "tools": [{"name": "get_weather", "input_schema": {"type": "object"}}, {"type": "web_search_20250305", "name": "web_search", "max_uses": 2}]}}`,
+			customId:  "b",
+			model:     anthropic.HAIKU_3_5,
+			maxTokens: 50,
+			tools:     []anthropic.ToolName{"get_weather", anthropic.WEB_SEARCH},
+		},
+		{
+			name:      "params without model or max_tokens",
+			line:      `{"params": {"messages": [{"role": "user", "content": "Hi"}]}}`,
+			customId:  "line-1",
+			model:     anthropic.SONNET_4,
+			maxTokens: 1000,
+		},
+		{
+			// Left for submit, which looks up the model's default
+			name:     "params for another model without max_tokens",
+			line:     `{"custom_id": "d", "params": {"model": "claude-3-5-haiku-20241022", "messages": [{"role": "user", "content": "Hi"}]}}`,
+			customId: "d",
+			model:    anthropic.HAIKU_3_5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, err := readBatchPrompts(writePrompts(t, tt.line), defaults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(requests) != 1 {
+				t.Fatalf("Expected 1 request, got %v", len(requests))
+			}
+			r := requests[0]
+			if r.CustomId != tt.customId || r.Params.Model != tt.model || r.Params.MaxTokens != tt.maxTokens {
+				t.Errorf("Expected %v, %v, %v, got %v, %v, %v", tt.customId, tt.model, tt.maxTokens, r.CustomId, r.Params.Model, r.Params.MaxTokens)
+			}
+			if r.Params.System.Text() != tt.system {
+				t.Errorf("Expected system prompt %q, got %q", tt.system, r.Params.System.Text())
+			}
+			names := []anthropic.ToolName{}
+			for _, tool := range r.Params.Tools {
+				names = append(names, tool.GetName())
+			}
+			if !slices.Equal(names, tt.tools) {
+				t.Errorf("Expected tools %v, got %v", tt.tools, names)
+			}
+		})
+	}
+}
+
+func TestReadBatchPromptsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		err   string
+	}{
+		{"invalid json", []string{`{"prompt": `}, "Invalid prompt on line 1"},
+		{"no prompt or params", []string{``, `{"custom_id": "a"}`}, "Line 2 has neither a prompt nor params"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readBatchPrompts(writePrompts(t, tt.lines...), anthropic.AnthropicMessagesRequest{Model: anthropic.SONNET_4})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestBatchSubmitAndResults(t *testing.T) {
+	srv := anthropictest.NewServer(
+		anthropictest.Reply(anthropic.SR_END_TURN, anthropictest.Text("Bonjour")).Expect(func(r *anthropictest.Request) error {
+			if r.Raw["max_tokens"] != float64(100) {
+				return fmt.Errorf("Expected max_tokens 100, got %v", r.Raw["max_tokens"])
+			}
+			return nil
+		}),
+		anthropictest.Error(500, "api_error", "Internal server error").Expect(func(r *anthropictest.Request) error {
+			tools, _ := r.Raw["tools"].([]any)
+			if len(tools) != 1 || tools[0].(map[string]any)["input_schema"] == nil {
+				return fmt.Errorf("Expected the tool to be sent as given, got %v", r.Raw["tools"])
+			}
+			return nil
+		}),
+	)
+	defer srv.Close()
+	client := clients.NewAnthropicClient(clients.WithBaseUrl(srv.URL), clients.WithApiKey("test"))
+	path := writePrompts(t,
+		`{"custom_id": "french", "prompt": "Say hello in French"}`,
+		`{"custom_id": "tools", "params": {"messages": [{"role": "user", "content": "Hi"}], "tools": [{"name": "get_weather", "input_schema": {"type": "object"}}]}}`,
+	)
+
+	var submitted strings.Builder
+	if err := submitBatch(context.Background(), client, []string{"-file", path, "-max-tokens", "100"}, &submitted); err != nil {
+		t.Fatal(err)
+	}
+	id, _, _ := strings.Cut(submitted.String(), "\t")
+	if !strings.Contains(submitted.String(), "succeeded=1 errored=1") {
+		t.Errorf("Expected the batch counts, got %q", submitted.String())
+	}
+
+	var results strings.Builder
+	if err := printBatchResults(context.Background(), client, id, &results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(results.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 results, got %q", results.String())
+	}
+	want := []batchOutput{
+		{CustomId: "french", Type: anthropic.BATCH_SUCCEEDED, Text: "Bonjour"},
+		{CustomId: "tools", Type: anthropic.BATCH_ERRORED, Error: "api_error: Internal server error"},
+	}
+	for i, line := range lines {
+		var got batchOutput
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], got)
+		}
+	}
+	if err := srv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,260 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+const (
+	ANTHROPIC_BASE_URL    string        = "https://api.anthropic.com"
+	ANTHROPIC_VERSION     string        = "2023-06-01"
+	API_KEY_ENV           string        = "GA_ANTHROPIC_API_KEY"
+	DEFAULT_MAX_RETRIES   int           = 2
+	DEFAULT_RETRY_BACKOFF time.Duration = time.Millisecond * 500
+	MAX_RETRY_BACKOFF     time.Duration = time.Second * 30
+)
+
+type AnthropicClient struct {
+	apiKey       string
+	baseUrl      string
+	httpClient   *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+type AnthropicClientOption func(*AnthropicClient)
+
+func WithApiKey(apiKey string) AnthropicClientOption {
+	return func(c *AnthropicClient) {
+		c.apiKey = apiKey
+	}
+}
+
+func WithBaseUrl(baseUrl string) AnthropicClientOption {
+	return func(c *AnthropicClient) {
+		c.baseUrl = baseUrl
+	}
+}
+
+func WithHttpClient(httpClient *http.Client) AnthropicClientOption {
+	return func(c *AnthropicClient) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sends requests through rt, e.g. a replay.Transport in tests
+func WithTransport(rt http.RoundTripper) AnthropicClientOption {
+	return func(c *AnthropicClient) {
+		c.httpClient = &http.Client{Transport: rt}
+	}
+}
+
+// WithMaxRetries sets how many times rate limited, overloaded and failed
+// requests are retried
+func WithMaxRetries(maxRetries int) AnthropicClientOption {
+	return func(c *AnthropicClient) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry, doubled for each
+// retry after it. A retry-after header from the API takes precedence.
+func WithRetryBackoff(backoff time.Duration) AnthropicClientOption {
+	return func(c *AnthropicClient) {
+		c.retryBackoff = backoff
+	}
+}
+
+// NewAnthropicClient creates a client for the Anthropic API. The API key is
+// read from GA_ANTHROPIC_API_KEY unless WithApiKey is given.
+func NewAnthropicClient(opts ...AnthropicClientOption) *AnthropicClient {
+	c := &AnthropicClient{
+		apiKey:       os.Getenv(API_KEY_ENV),
+		baseUrl:      ANTHROPIC_BASE_URL,
+		httpClient:   http.DefaultClient,
+		maxRetries:   DEFAULT_MAX_RETRIES,
+		retryBackoff: DEFAULT_RETRY_BACKOFF,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// AnthropicError is an error response returned by the API
+type AnthropicError struct {
+	StatusCode int
+	Type       string
+	Message    string
+	RequestId  string
+}
+
+func (e *AnthropicError) Error() string {
+	return fmt.Sprintf("Anthropic error {type: '%v' message: '%v'}", e.Type, e.Message)
+}
+
+func (c *AnthropicClient) PostMessage(ctx context.Context, request *anthropic.AnthropicMessagesRequest) (*anthropic.MessagesResponse, error) {
+	resBytes, err := c.do(ctx, http.MethodPost, "/v1/messages", request, request.Betas)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &anthropic.MessagesResponse{}
+	if err := json.Unmarshal(resBytes, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// apiRequest describes a single API call. Responses to raw requests are
+// returned as is instead of being checked for a JSON error body.
+type apiRequest struct {
+	method      string
+	path        string
+	body        []byte
+	contentType string
+	betas       []string
+	raw         bool
+}
+
+func (c *AnthropicClient) do(ctx context.Context, method string, path string, body any, betas []string) ([]byte, error) {
+	req := apiRequest{method: method, path: path, betas: betas}
+	if body != nil {
+		var err error
+		req.body, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		req.contentType = "application/json"
+	}
+	return c.doRequest(ctx, req)
+}
+
+func (c *AnthropicClient) doRequest(ctx context.Context, r apiRequest) ([]byte, error) {
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		content, retryAfter, err := c.send(ctx, r)
+		if err == nil || attempt >= c.maxRetries || !retryable(err) {
+			return content, err
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(wait, MAX_RETRY_BACKOFF)):
+		}
+		backoff *= 2
+	}
+}
+
+// send makes a single request, returning the response body and any delay
+// requested by a retry-after header
+func (c *AnthropicClient) send(ctx context.Context, r apiRequest) ([]byte, time.Duration, error) {
+	res, err := c.open(ctx, r)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(res.Header.Get("retry-after")); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, retryAfter, err
+	}
+
+	if r.raw && res.StatusCode < http.StatusBadRequest {
+		return content, retryAfter, nil
+	}
+	if err := checkResponseErr(res.StatusCode, content); err != nil {
+		return nil, retryAfter, err
+	}
+	return content, retryAfter, nil
+}
+
+// open makes a request and returns the response without reading its body
+func (c *AnthropicClient) open(ctx context.Context, r apiRequest) (*http.Response, error) {
+	var bodyReader io.Reader
+	if r.body != nil {
+		bodyReader = bytes.NewReader(r.body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, c.baseUrl+r.path, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("x-api-key", c.apiKey)
+	req.Header.Add("anthropic-version", ANTHROPIC_VERSION)
+	if r.contentType != "" {
+		req.Header.Add("content-type", r.contentType)
+	}
+	if len(r.betas) > 0 {
+		req.Header.Add("anthropic-beta", strings.Join(r.betas, ","))
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// retryable reports whether a request that failed with err may succeed if sent again
+func retryable(err error) bool {
+	var apiErr *AnthropicError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests, 529:
+			return true
+		}
+		return apiErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	// Network errors, but not cancellation
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return false
+}
+
+func checkResponseErr(statusCode int, data []byte) error {
+	baseRes := &anthropic.MessagesBaseResponse{}
+	if err := json.Unmarshal(data, baseRes); err != nil {
+		if statusCode >= http.StatusBadRequest {
+			return &AnthropicError{StatusCode: statusCode, Type: "http_error", Message: string(data)}
+		}
+		return err
+	}
+
+	if baseRes.Type == "error" || statusCode >= http.StatusBadRequest {
+		errRes := &anthropic.MessagesErrorResponse{}
+		if err := json.Unmarshal(data, errRes); err != nil {
+			return err
+		}
+
+		return &AnthropicError{
+			StatusCode: statusCode,
+			Type:       errRes.Error.Type,
+			Message:    errRes.Error.Message,
+			RequestId:  errRes.RequestId,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package clients_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frozenkro/go-agent/clients"
+	"github.com/frozenkro/go-agent/clients/anthropictest"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func testClient(srv *anthropictest.Server, opts ...clients.AnthropicClientOption) *clients.AnthropicClient {
+	opts = append([]clients.AnthropicClientOption{clients.WithBaseUrl(srv.URL), clients.WithApiKey("test")}, opts...)
+	return clients.NewAnthropicClient(opts...)
+}
+
+func testRequest(messages ...anthropic.Message) *anthropic.AnthropicMessagesRequest {
+	if len(messages) == 0 {
+		messages = []anthropic.Message{{Role: anthropic.USER, Content: []anthropic.Content{anthropic.NewTextContent("Hello")}}}
+	}
+	return &anthropic.AnthropicMessagesRequest{
+		Model:     anthropic.SONNET_4,
+		MaxTokens: 1024,
+		Messages:  messages,
+	}
+}
+
+func responseText(t *testing.T, response *anthropic.MessagesResponse) string {
+	t.Helper()
+	if len(response.Content) != 1 {
+		t.Fatalf("Expected a single content block, got %v", len(response.Content))
+	}
+	text, ok := response.Content[0].(*anthropic.TextContent)
+	if !ok {
+		t.Fatalf("Expected a text block, got %T", response.Content[0])
+	}
+	return text.Text
+}
+
+func TestPostMessageRetriesServerErrors(t *testing.T) {
+	srv := anthropictest.NewServer(
+		anthropictest.Error(529, "overloaded_error", "Overloaded"),
+		anthropictest.Error(http.StatusInternalServerError, "api_error", "Internal server error"),
+		anthropictest.Reply(anthropic.SR_END_TURN, anthropictest.Text("ok")),
+	)
+	defer srv.Close()
+
+	client := testClient(srv, clients.WithMaxRetries(2), clients.WithRetryBackoff(time.Millisecond))
+	response, err := client.PostMessage(context.Background(), testRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := responseText(t, response); text != "ok" {
+		t.Errorf("Expected 'ok', got '%v'", text)
+	}
+	if err := srv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostMessageWaitsForRetryAfter(t *testing.T) {
+	srv := anthropictest.NewServer(
+		anthropictest.Error(http.StatusTooManyRequests, "rate_limit_error", "Rate limited").WithHeader("retry-after", "1"),
+		anthropictest.Reply(anthropic.SR_END_TURN, anthropictest.Text("ok")),
+	)
+	defer srv.Close()
+
+	// The backoff would outlast the context, so only retry-after can be used
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := testClient(srv, clients.WithMaxRetries(1), clients.WithRetryBackoff(time.Hour))
+
+	start := time.Now()
+	if _, err := client.PostMessage(ctx, testRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected the retry to wait for retry-after, it was sent after %v", elapsed)
+	}
+	if err := srv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostMessageGivesUpAfterMaxRetries(t *testing.T) {
+	srv := anthropictest.NewServer(
+		anthropictest.Error(529, "overloaded_error", "Overloaded"),
+		anthropictest.Error(529, "overloaded_error", "Overloaded"),
+		anthropictest.Error(529, "overloaded_error", "Overloaded"),
+	)
+	defer srv.Close()
+
+	client := testClient(srv, clients.WithMaxRetries(2), clients.WithRetryBackoff(time.Millisecond))
+	_, err := client.PostMessage(context.Background(), testRequest())
+	var apiErr *clients.AnthropicError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 529 {
+		t.Fatalf("Expected an overloaded error, got %v", err)
+	}
+	if err := srv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostMessageDoesNotRetryInvalidRequests(t *testing.T) {
+	srv := anthropictest.NewServer(
+		anthropictest.Error(http.StatusBadRequest, "invalid_request_error", "max_tokens is required"),
+		anthropictest.Reply(anthropic.SR_END_TURN, anthropictest.Text("unused")),
+	)
+	defer srv.Close()
+
+	client := testClient(srv, clients.WithMaxRetries(2), clients.WithRetryBackoff(time.Millisecond))
+	_, err := client.PostMessage(context.Background(), testRequest())
+	var apiErr *clients.AnthropicError
+	if !errors.As(err, &apiErr) || apiErr.Type != "invalid_request_error" {
+		t.Fatalf("Expected an invalid request error, got %v", err)
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("Expected a single request, got %v", n)
+	}
+}
+
+func toolLoop(resultId string) []anthropic.Message {
+	return []anthropic.Message{
+		{Role: anthropic.USER, Content: []anthropic.Content{anthropic.NewTextContent("List the files")}},
+		{Role: anthropic.ASSISTANT, Content: []anthropic.Content{
+			anthropictest.ToolUse("toolu_1", anthropic.BASH, map[string]any{"command": "ls"}),
+		}},
+		{Role: anthropic.USER, Content: []anthropic.Content{anthropic.ToolResultContent{
+			BaseContent: anthropic.BaseContent{Type: anthropic.TOOL_RESULT},
+			ToolUseId:   resultId,
+			Content:     []anthropic.Content{anthropic.NewTextContent("main.go")},
+		}}},
+	}
+}
+
+func TestServerAcceptsMatchingToolResults(t *testing.T) {
+	srv := anthropictest.NewServer(anthropictest.Reply(anthropic.SR_END_TURN, anthropictest.Text("main.go")))
+	defer srv.Close()
+
+	if _, err := testClient(srv).PostMessage(context.Background(), testRequest(toolLoop("toolu_1")...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerRejectsMismatchedToolResults(t *testing.T) {
+	srv := anthropictest.NewServer(anthropictest.Reply(anthropic.SR_END_TURN, anthropictest.Text("main.go")))
+	defer srv.Close()
+
+	if _, err := testClient(srv).PostMessage(context.Background(), testRequest(toolLoop("toolu_2")...)); err != nil {
+		t.Fatal(err)
+	}
+	err := srv.Verify()
+	if err == nil || !strings.Contains(err.Error(), "unknown tool_use id 'toolu_2'") {
+		t.Fatalf("Expected the mismatched tool_result to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package anthropictest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+type batch struct {
+	meta    anthropic.MessageBatch
+	results []map[string]any
+}
+
+// handleBatches implements the Message Batches API. Batches are processed as
+// soon as they are created, each request receiving the next step of the
+// script.
+func (s *Server) handleBatches(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/messages/batches"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+
+	if id == "" {
+		switch r.Method {
+		case http.MethodPost:
+			s.createBatch(w, r)
+		case http.MethodGet:
+			list := anthropic.MessageBatchList{Data: []anthropic.MessageBatch{}}
+			for _, b := range s.batches {
+				list.Data = append(list.Data, b.meta)
+			}
+			writeJson(w, list)
+		default:
+			writeError(w, http.StatusNotFound, "not_found_error", "Not found")
+		}
+		return
+	}
+
+	b, ok := s.batches[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found_error", fmt.Sprintf("Batch '%v' not found", id))
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJson(w, b.meta)
+	case action == "cancel" && r.Method == http.MethodPost:
+		// Batches end as soon as they are created, so there is nothing to cancel
+		writeJson(w, b.meta)
+	case action == "results" && r.Method == http.MethodGet:
+		w.Header().Set("content-type", "application/binary")
+		for _, result := range b.results {
+			line, _ := json.Marshal(result)
+			fmt.Fprintf(w, "%s\n", line)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not_found_error", "Not found")
+	}
+}
+
+func (s *Server) createBatch(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	var create struct {
+		Requests []struct {
+			CustomId string          `json:"custom_id"`
+			Params   json.RawMessage `json:"params"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal(body, &create); err != nil {
+		s.fail(fmt.Errorf("Unable to decode batch: %w", err))
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	if s.batches == nil {
+		s.batches = make(map[string]*batch)
+	}
+	now := time.Now().UTC()
+	b := &batch{meta: anthropic.MessageBatch{
+		Id:               fmt.Sprintf("msgbatch_test_%v", len(s.batches)+1),
+		Type:             "message_batch",
+		ProcessingStatus: anthropic.BATCH_ENDED,
+		CreatedAt:        now.Format(time.RFC3339),
+		ExpiresAt:        now.Add(24 * time.Hour).Format(time.RFC3339),
+		EndedAt:          now.Format(time.RFC3339),
+	}}
+	b.meta.ResultsUrl = fmt.Sprintf("%v/v1/messages/batches/%v/results", s.URL, b.meta.Id)
+
+	for _, item := range create.Requests {
+		req, err := decodeBody(r, item.Params)
+		if err != nil {
+			s.fail(fmt.Errorf("Batch request '%v': %w", item.CustomId, err))
+			continue
+		}
+		step, n, ok := s.nextStep(req)
+		if !ok {
+			b.results = append(b.results, batchError(item.CustomId, "api_error", "No scripted response left"))
+			b.meta.RequestCounts.Errored++
+			continue
+		}
+
+		if step.errorType != "" {
+			b.results = append(b.results, batchError(item.CustomId, step.errorType, step.message))
+			b.meta.RequestCounts.Errored++
+			continue
+		}
+		s.replies = append(s.replies, step.content)
+		b.results = append(b.results, map[string]any{
+			"custom_id": item.CustomId,
+			"result": map[string]any{
+				"type":    anthropic.BATCH_SUCCEEDED,
+				"message": newMessage(fmt.Sprintf("msg_test_%v", n), req.Model, step),
+			},
+		})
+		b.meta.RequestCounts.Succeeded++
+	}
+
+	s.batches[b.meta.Id] = b
+	writeJson(w, b.meta)
+}
+
+func batchError(customId string, errorType string, message string) map[string]any {
+	return map[string]any{
+		"custom_id": customId,
+		"result": map[string]any{
+			"type": anthropic.BATCH_ERRORED,
+			"error": map[string]any{
+				"type":  "error",
+				"error": map[string]string{"type": errorType, "message": message},
+			},
+		},
+	}
+}
@@ -0,0 +1,117 @@
+package anthropictest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+type storedFile struct {
+	meta anthropic.File
+	data []byte
+}
+
+// AddFile stores a file as if a tool had created it, e.g. the output of code
+// execution, and returns its metadata
+func (s *Server) AddFile(filename string, mediaType anthropic.MediaType, data []byte) anthropic.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.storeFile(filename, mediaType, data, true)
+}
+
+func (s *Server) storeFile(filename string, mediaType anthropic.MediaType, data []byte, downloadable bool) anthropic.File {
+	if s.files == nil {
+		s.files = make(map[string]*storedFile)
+	}
+	s.fileCount++
+	meta := anthropic.File{
+		Id:           fmt.Sprintf("file_test_%v", s.fileCount),
+		Type:         "file",
+		Filename:     filename,
+		MimeType:     mediaType,
+		SizeBytes:    int64(len(data)),
+		CreatedAt:    time.Now().UTC().Format(time.RFC3339),
+		Downloadable: downloadable,
+	}
+	s.files[meta.Id] = &storedFile{meta: meta, data: data}
+	s.fileOrder = append(s.fileOrder, meta.Id)
+	return meta
+}
+
+// handleFiles implements the Files API in memory. Uploaded files cannot be
+// downloaded, as with the real API.
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("anthropic-beta"), anthropic.BETA_FILES_API) {
+		s.fail(fmt.Errorf("Files API request %v %v has no %v beta header", r.Method, r.URL.Path, anthropic.BETA_FILES_API))
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/files"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		writeJson(w, s.storeFile(header.Filename, anthropic.MediaType(header.Header.Get("Content-Type")), data, false))
+
+	case id == "" && r.Method == http.MethodGet:
+		list := anthropic.FileList{Data: []anthropic.File{}}
+		for _, fileId := range s.fileOrder {
+			list.Data = append(list.Data, s.files[fileId].meta)
+		}
+		if len(list.Data) > 0 {
+			list.FirstId = list.Data[0].Id
+			list.LastId = list.Data[len(list.Data)-1].Id
+		}
+		writeJson(w, list)
+
+	default:
+		f, ok := s.files[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found_error", fmt.Sprintf("File '%v' not found", id))
+			return
+		}
+
+		switch {
+		case action == "" && r.Method == http.MethodGet:
+			writeJson(w, f.meta)
+		case action == "" && r.Method == http.MethodDelete:
+			delete(s.files, id)
+			for i, fileId := range s.fileOrder {
+				if fileId == id {
+					s.fileOrder = append(s.fileOrder[:i], s.fileOrder[i+1:]...)
+					break
+				}
+			}
+			writeJson(w, map[string]string{"id": id, "type": "file_deleted"})
+		case action == "content" && r.Method == http.MethodGet:
+			if !f.meta.Downloadable {
+				writeError(w, http.StatusBadRequest, "invalid_request_error", "Uploaded files cannot be downloaded")
+				return
+			}
+			w.Header().Set("content-type", string(f.meta.MimeType))
+			w.Write(f.data)
+		default:
+			writeError(w, http.StatusNotFound, "not_found_error", "Not found")
+		}
+	}
+}
+
+func writeJson(w http.ResponseWriter, v any) {
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
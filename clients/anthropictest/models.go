@@ -0,0 +1,37 @@
+package anthropictest
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// handleModels lists Models, or the known models when it is nil, a page at a
+// time as selected by the limit and after_id parameters
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	models := s.Models
+	if models == nil {
+		for _, m := range anthropic.KNOWN_MODELS {
+			models = append(models, anthropic.ModelEntry{Id: m.Model, Type: "model", DisplayName: m.DisplayName})
+		}
+	}
+
+	if after := r.URL.Query().Get("after_id"); after != "" {
+		i := slices.IndexFunc(models, func(m anthropic.ModelEntry) bool { return string(m.Id) == after })
+		models = models[i+1:]
+	}
+	hasMore := false
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit < len(models) {
+		models = models[:limit]
+		hasMore = true
+	}
+
+	list := anthropic.ModelList{Data: models, HasMore: hasMore}
+	if len(models) > 0 {
+		list.FirstId = string(models[0].Id)
+		list.LastId = string(models[len(models)-1].Id)
+	}
+	writeJson(w, list)
+}
@@ -0,0 +1,486 @@
+// Package anthropictest provides a scripted fake of the Anthropic Messages
+// API for integration testing agents against the real client code.
+//
+//	srv := anthropictest.NewServer(
+//		anthropictest.Reply(anthropic.SR_TOOL_USE, anthropictest.ToolUse("tu_1", anthropic.BASH, map[string]any{"command": "ls"})),
+//		anthropictest.Reply(anthropic.SR_END_TURN, anthropictest.Text("done")),
+//	)
+//	defer srv.Close()
+//	client := clients.NewAnthropicClient(clients.WithBaseUrl(srv.URL))
+//	...
+//	if err := srv.Verify(); err != nil { t.Fatal(err) }
+package anthropictest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// Request is a decoded request received by the server
+type Request struct {
+	Method   string
+	Path     string
+	Header   http.Header
+	Model    anthropic.Model     `json:"model"`
+	Messages []anthropic.Message `json:"messages"`
+	Stream   bool                `json:"stream"`
+	// Raw holds the full decoded body, including fields not decoded above
+	Raw map[string]any `json:"-"`
+}
+
+// Step is one scripted response, optionally with assertions on the request
+// that receives it
+type Step struct {
+	status     int
+	errorType  string
+	message    string
+	stop       anthropic.StopReason
+	content    []anthropic.Content
+	header     http.Header
+	assertions []func(*Request) error
+}
+
+// Reply responds with an assistant message with the given stop reason and content
+func Reply(stop anthropic.StopReason, content ...anthropic.Content) Step {
+	return Step{
+		status:  http.StatusOK,
+		stop:    stop,
+		content: content,
+	}
+}
+
+// Error responds with an API error, e.g. Error(529, "overloaded_error", "Overloaded")
+func Error(status int, errorType string, message string) Step {
+	return Step{
+		status:    status,
+		errorType: errorType,
+		message:   message,
+	}
+}
+
+// Expect adds an assertion run against the request that receives this step
+func (s Step) Expect(assertion func(*Request) error) Step {
+	s.assertions = append(s.assertions, assertion)
+	return s
+}
+
+// WithHeader adds a response header, e.g. retry-after
+func (s Step) WithHeader(key string, value string) Step {
+	if s.header == nil {
+		s.header = http.Header{}
+	}
+	s.header.Add(key, value)
+	return s
+}
+
+func Text(text string) anthropic.Content {
+	return &anthropic.TextContent{
+		BaseContent: anthropic.BaseContent{Type: anthropic.TEXT},
+		Text:        text,
+	}
+}
+
+func RedactedThinking(data string) anthropic.Content {
+	return &anthropic.RedactedThinkingContent{
+		BaseContent: anthropic.BaseContent{Type: anthropic.REDACTED_THINKING},
+		Data:        data,
+	}
+}
+
+func ToolUse(id string, name anthropic.ToolName, input any) anthropic.Content {
+	return &anthropic.ToolUseContent{
+		BaseContent: anthropic.BaseContent{Type: anthropic.TOOL_USE},
+		Id:          id,
+		Name:        name,
+		Input:       input,
+	}
+}
+
+// ServerToolUse is a call to a tool the API runs itself, e.g. web search
+func ServerToolUse(id string, name anthropic.ToolName, input any) anthropic.Content {
+	return &anthropic.ServerToolUseContent{
+		BaseContent: anthropic.BaseContent{Type: anthropic.SERVER_TOOL_USE},
+		Id:          id,
+		Name:        name,
+		Input:       input,
+	}
+}
+
+// WebSearchResult answers a ServerToolUse of web search with results
+// for the given urls
+func WebSearchResult(toolUseId string, urls ...string) anthropic.Content {
+	results := []anthropic.WebSearchResult{}
+	for _, url := range urls {
+		results = append(results, anthropic.WebSearchResult{Type: "web_search_result", Url: url, Title: url, EncryptedContent: "encrypted"})
+	}
+	return &anthropic.WebSearchToolResultContent{
+		BaseContent: anthropic.BaseContent{Type: anthropic.WEB_SEARCH_TOOL_RESULT},
+		ToolUseId:   toolUseId,
+		Results:     results,
+	}
+}
+
+// CodeExecutionResult answers a ServerToolUse of code execution with its
+// output and the ids of the files it wrote
+func CodeExecutionResult(toolUseId string, stdout string, stderr string, returnCode int, fileIds ...string) anthropic.Content {
+	outputs := []anthropic.CodeExecutionToolResultContentContentContent{}
+	for _, id := range fileIds {
+		outputs = append(outputs, anthropic.CodeExecutionToolResultContentContentContent{
+			BaseContent: anthropic.BaseContent{Type: anthropic.CODE_EXECUTION_OUTPUT},
+			FileId:      id,
+		})
+	}
+	return &anthropic.CodeExecutionToolResultContent{
+		BaseContent: anthropic.BaseContent{Type: anthropic.CODE_EXECUTION_TOOL_RESULT},
+		ToolUseId:   toolUseId,
+		Content: &anthropic.CodeExecutionToolResultContentContent{
+			BaseContent: anthropic.BaseContent{Type: anthropic.CODE_EXECUTION_RESULT},
+			Content:     outputs,
+			ReturnCode:  returnCode,
+			StdErr:      stderr,
+			StdOut:      stdout,
+		},
+	}
+}
+
+func Thinking(thinking string, signature string) anthropic.Content {
+	return &anthropic.ThinkingContent{
+		BaseContent: anthropic.BaseContent{Type: anthropic.THINKING},
+		Thinking:    thinking,
+		Signature:   signature,
+	}
+}
+
+type Server struct {
+	*httptest.Server
+
+	// CountTokens answers /v1/messages/count_tokens. Defaults to a quarter of
+	// the size of the request body.
+	CountTokens func(req *Request, body []byte) int
+	// Models are listed by /v1/models. Defaults to anthropic.KNOWN_MODELS.
+	Models []anthropic.ModelEntry
+
+	mu       sync.Mutex
+	steps    []Step
+	next     int
+	replies  [][]anthropic.Content
+	requests []*Request
+
+	batches map[string]*batch
+
+	files     map[string]*storedFile
+	fileOrder []string
+	fileCount int
+	errs      []error
+}
+
+// NewServer starts a server that answers each request to /v1/messages with
+// the next step of the script. Each request of a message batch also receives
+// the next step, and requests to /v1/files are served from memory.
+func NewServer(steps ...Step) *Server {
+	s := &Server{steps: steps}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Requests returns every request received so far
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request{}, s.requests...)
+}
+
+// Verify returns the failed assertions and an error if any step was not used
+func (s *Server) Verify() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := append([]error{}, s.errs...)
+	if s.next < len(s.steps) {
+		errs = append(errs, fmt.Errorf("%v of %v scripted responses were not requested", len(s.steps)-s.next, len(s.steps)))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) fail(err error) {
+	s.errs = append(s.errs, err)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/v1/files") {
+		s.handleFiles(w, r)
+		return
+	}
+
+	if r.URL.Path == "/v1/models" {
+		s.handleModels(w, r)
+		return
+	}
+
+	if r.URL.Path == "/v1/messages/count_tokens" {
+		s.countTokens(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/v1/messages/batches") {
+		s.handleBatches(w, r)
+		return
+	}
+
+	if r.URL.Path != "/v1/messages" {
+		s.fail(fmt.Errorf("Unexpected request to %v %v", r.Method, r.URL.Path))
+		writeError(w, http.StatusNotFound, "not_found_error", "Not found")
+		return
+	}
+
+	req, err := decodeRequest(r)
+	if err != nil {
+		s.fail(err)
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	step, n, ok := s.nextStep(req)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "api_error", "No scripted response left")
+		return
+	}
+
+	for key, values := range step.header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+
+	if step.errorType != "" {
+		if req.Stream && step.status == http.StatusOK {
+			writeStreamError(w, step.errorType, step.message)
+			return
+		}
+		writeError(w, step.status, step.errorType, step.message)
+		return
+	}
+
+	s.replies = append(s.replies, step.content)
+	message := newMessage(fmt.Sprintf("msg_test_%v", n), req.Model, step)
+	if req.Stream {
+		writeStream(w, message)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// nextStep records req and returns the step that answers it and the number
+// of the request, after checking the request against the step's assertions
+func (s *Server) nextStep(req *Request) (Step, int, bool) {
+	s.requests = append(s.requests, req)
+	n := len(s.requests)
+
+	if s.next >= len(s.steps) {
+		s.fail(fmt.Errorf("Request %v received after the script ended", n))
+		return Step{}, n, false
+	}
+	step := s.steps[s.next]
+	s.next++
+
+	if err := checkToolResults(req); err != nil {
+		s.fail(fmt.Errorf("Request %v: %w", n, err))
+	}
+	if err := checkThinking(req, s.replies); err != nil {
+		s.fail(fmt.Errorf("Request %v: %w", n, err))
+	}
+	for _, assertion := range step.assertions {
+		if err := assertion(req); err != nil {
+			s.fail(fmt.Errorf("Request %v: %w", n, err))
+		}
+	}
+	return step, n, true
+}
+
+func (s *Server) countTokens(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	req, err := decodeBody(r, body)
+	if err != nil {
+		s.fail(err)
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	tokens := len(body) / 4
+	if s.CountTokens != nil {
+		tokens = s.CountTokens(req, body)
+	}
+	writeJson(w, anthropic.TokenCount{InputTokens: tokens})
+}
+
+func decodeRequest(r *http.Request) (*Request, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := decodeBody(r, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.Header.Get("x-api-key") == "" {
+		return nil, fmt.Errorf("Request has no x-api-key header")
+	}
+	if r.Header.Get("anthropic-version") == "" {
+		return nil, fmt.Errorf("Request has no anthropic-version header")
+	}
+	return req, nil
+}
+
+// decodeBody decodes a messages request body received with r, which is
+// either the request itself or a batch containing it
+func decodeBody(r *http.Request, body []byte) (*Request, error) {
+	req := &Request{Method: r.Method, Path: r.URL.Path, Header: r.Header}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, fmt.Errorf("Unable to decode request body: %w", err)
+	}
+	if err := json.Unmarshal(body, &req.Raw); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// checkToolResults asserts that every tool_use in the last assistant message
+// is answered by a tool_result with a matching id in the message after it
+func checkToolResults(req *Request) error {
+	for i := 0; i+1 < len(req.Messages); i++ {
+		if req.Messages[i].Role != anthropic.ASSISTANT {
+			continue
+		}
+
+		toolUseIds := map[string]bool{}
+		for _, c := range req.Messages[i].Content {
+			if toolUse, ok := c.(*anthropic.ToolUseContent); ok && c.GetType() == anthropic.TOOL_USE {
+				toolUseIds[toolUse.Id] = true
+			}
+		}
+		if len(toolUseIds) == 0 {
+			continue
+		}
+
+		results := map[string]bool{}
+		for _, c := range req.Messages[i+1].Content {
+			if result, ok := c.(*anthropic.ToolResultContent); ok {
+				if !toolUseIds[result.ToolUseId] {
+					return fmt.Errorf("tool_result for unknown tool_use id '%v' in message %v", result.ToolUseId, i+1)
+				}
+				results[result.ToolUseId] = true
+			}
+		}
+		for id := range toolUseIds {
+			if !results[id] {
+				return fmt.Errorf("tool_use id '%v' in message %v has no tool_result", id, i)
+			}
+		}
+	}
+	return nil
+}
+
+// checkThinking asserts that assistant messages ending in tool use carry the
+// thinking blocks of the reply they came from unchanged, as the API requires
+func checkThinking(req *Request, replies [][]anthropic.Content) error {
+	for i, m := range req.Messages {
+		if m.Role != anthropic.ASSISTANT {
+			continue
+		}
+		reply := replyWithToolUse(m.Content, replies)
+		if reply == nil {
+			continue
+		}
+
+		want, got := thinkingBlocks(reply), thinkingBlocks(m.Content)
+		if !slices.Equal(want, got) {
+			return fmt.Errorf("thinking blocks in message %v were changed or dropped: sent %v, got back %v", i, len(want), len(got))
+		}
+	}
+	return nil
+}
+
+// replyWithToolUse finds the reply that contained the first tool_use of content
+func replyWithToolUse(content []anthropic.Content, replies [][]anthropic.Content) []anthropic.Content {
+	for _, c := range content {
+		toolUse, ok := c.(*anthropic.ToolUseContent)
+		if !ok {
+			continue
+		}
+		for _, reply := range replies {
+			for _, rc := range reply {
+				if sent, ok := rc.(*anthropic.ToolUseContent); ok && sent.Id == toolUse.Id {
+					return reply
+				}
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+func thinkingBlocks(content []anthropic.Content) []string {
+	blocks := []string{}
+	for _, c := range content {
+		switch t := c.(type) {
+		case *anthropic.ThinkingContent:
+			blocks = append(blocks, t.Thinking+"\x00"+t.Signature)
+		case *anthropic.RedactedThinkingContent:
+			blocks = append(blocks, t.Data)
+		}
+	}
+	return blocks
+}
+
+type message struct {
+	Id           string                  `json:"id"`
+	Type         string                  `json:"type"`
+	Role         anthropic.Role          `json:"role"`
+	Model        anthropic.Model         `json:"model"`
+	Content      []anthropic.Content     `json:"content"`
+	StopReason   anthropic.StopReason    `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        anthropic.MessagesUsage `json:"usage"`
+}
+
+func newMessage(id string, model anthropic.Model, step Step) message {
+	content := step.content
+	if content == nil {
+		content = []anthropic.Content{}
+	}
+	return message{
+		Id:         id,
+		Type:       "message",
+		Role:       anthropic.ASSISTANT,
+		Model:      model,
+		Content:    content,
+		StopReason: step.stop,
+		Usage:      anthropic.MessagesUsage{InputTokens: 10, OutputTokens: 10},
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, errorType string, message string) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"type":  "error",
+		"error": map[string]string{"type": errorType, "message": message},
+	})
+}
@@ -0,0 +1,72 @@
+package anthropictest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// writeStream sends message as server-sent events in the order the Messages
+// API uses, with each content block delivered as a single delta
+func writeStream(w http.ResponseWriter, msg message) {
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+
+	start := msg
+	start.Content = []anthropic.Content{}
+	start.StopReason = ""
+	writeEvent(w, "message_start", map[string]any{"type": "message_start", "message": start})
+
+	for i, c := range msg.Content {
+		block, delta := splitBlock(c)
+		writeEvent(w, "content_block_start", map[string]any{"type": "content_block_start", "index": i, "content_block": block})
+		if delta != nil {
+			writeEvent(w, "content_block_delta", map[string]any{"type": "content_block_delta", "index": i, "delta": delta})
+		}
+		writeEvent(w, "content_block_stop", map[string]any{"type": "content_block_stop", "index": i})
+	}
+
+	writeEvent(w, "message_delta", map[string]any{
+		"type":  "message_delta",
+		"delta": map[string]any{"stop_reason": msg.StopReason, "stop_sequence": nil},
+		"usage": map[string]any{"output_tokens": msg.Usage.OutputTokens},
+	})
+	writeEvent(w, "message_stop", map[string]any{"type": "message_stop"})
+}
+
+func writeStreamError(w http.ResponseWriter, errorType string, message string) {
+	w.Header().Set("content-type", "text/event-stream")
+	writeEvent(w, "error", map[string]any{
+		"type":  "error",
+		"error": map[string]string{"type": errorType, "message": message},
+	})
+}
+
+// splitBlock returns the empty block sent in content_block_start and the
+// delta that fills it in
+func splitBlock(c anthropic.Content) (any, any) {
+	switch c := c.(type) {
+	case *anthropic.TextContent:
+		return map[string]any{"type": anthropic.TEXT, "text": ""}, map[string]any{"type": "text_delta", "text": c.Text}
+	case *anthropic.ThinkingContent:
+		return map[string]any{"type": anthropic.THINKING, "thinking": "", "signature": c.Signature}, map[string]any{"type": "thinking_delta", "thinking": c.Thinking}
+	case *anthropic.ToolUseContent:
+		input, _ := json.Marshal(c.Input)
+		return map[string]any{"type": c.Type, "id": c.Id, "name": c.Name, "input": map[string]any{}}, map[string]any{"type": "input_json_delta", "partial_json": string(input)}
+	case *anthropic.ServerToolUseContent:
+		input, _ := json.Marshal(c.Input)
+		return map[string]any{"type": c.Type, "id": c.Id, "name": c.Name, "input": map[string]any{}}, map[string]any{"type": "input_json_delta", "partial_json": string(input)}
+	default:
+		return c, nil
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, data any) {
+	encoded, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %v\ndata: %s\n\n", event, encoded)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
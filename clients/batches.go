@@ -0,0 +1,125 @@
+package clients
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+type ListBatchesParams struct {
+	Limit    int
+	AfterId  string
+	BeforeId string
+}
+
+// CreateBatch submits requests to be processed asynchronously. Beta features
+// enabled on any of the requests are enabled for the whole batch.
+func (c *AnthropicClient) CreateBatch(ctx context.Context, requests []anthropic.BatchRequest) (*anthropic.MessageBatch, error) {
+	betas := []string{}
+	for _, r := range requests {
+		for _, beta := range r.Params.Betas {
+			if !slices.Contains(betas, beta) {
+				betas = append(betas, beta)
+			}
+		}
+	}
+
+	body := map[string]any{"requests": requests}
+	content, err := c.do(ctx, http.MethodPost, "/v1/messages/batches", body, betas)
+	if err != nil {
+		return nil, err
+	}
+	return decode[anthropic.MessageBatch](content)
+}
+
+func (c *AnthropicClient) GetBatch(ctx context.Context, batchId string) (*anthropic.MessageBatch, error) {
+	content, err := c.do(ctx, http.MethodGet, "/v1/messages/batches/"+url.PathEscape(batchId), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decode[anthropic.MessageBatch](content)
+}
+
+func (c *AnthropicClient) ListBatches(ctx context.Context, params ListBatchesParams) (*anthropic.MessageBatchList, error) {
+	query := url.Values{}
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.AfterId != "" {
+		query.Set("after_id", params.AfterId)
+	}
+	if params.BeforeId != "" {
+		query.Set("before_id", params.BeforeId)
+	}
+
+	path := "/v1/messages/batches"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	content, err := c.do(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decode[anthropic.MessageBatchList](content)
+}
+
+// CancelBatch stops processing a batch. Requests already processed keep
+// their results.
+func (c *AnthropicClient) CancelBatch(ctx context.Context, batchId string) (*anthropic.MessageBatch, error) {
+	content, err := c.do(ctx, http.MethodPost, "/v1/messages/batches/"+url.PathEscape(batchId)+"/cancel", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decode[anthropic.MessageBatch](content)
+}
+
+// BatchResults streams the results of an ended batch as they are read,
+// without loading the whole results file into memory
+func (c *AnthropicClient) BatchResults(ctx context.Context, batchId string) iter.Seq2[*anthropic.BatchResult, error] {
+	return func(yield func(*anthropic.BatchResult, error) bool) {
+		res, err := c.open(ctx, apiRequest{method: http.MethodGet, path: "/v1/messages/batches/" + url.PathEscape(batchId) + "/results"})
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode >= http.StatusBadRequest {
+			content, _ := io.ReadAll(res.Body)
+			yield(nil, checkResponseErr(res.StatusCode, content))
+			return
+		}
+
+		reader := bufio.NewReader(res.Body)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				result := &anthropic.BatchResult{}
+				if decodeErr := json.Unmarshal(line, result); decodeErr != nil {
+					yield(nil, decodeErr)
+					return
+				}
+				if !yield(result, nil) {
+					return
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// countTokensRequest holds the fields of a messages request that count
+// towards its input tokens
+type countTokensRequest struct {
+	Model      anthropic.Model               `json:"model"`
+	Messages   []anthropic.Message           `json:"messages"`
+	System     anthropic.SystemPrompt        `json:"system,omitempty"`
+	Thinking   *anthropic.ThinkingData       `json:"thinking,omitempty"`
+	ToolChoice *anthropic.ToolChoice         `json:"tool_choice,omitempty"`
+	Tools      []anthropic.AnthropicToolSpec `json:"tools,omitempty"`
+	MCPServers []anthropic.MCPServer         `json:"mcp_servers,omitempty"`
+}
+
+// CountTokens returns the number of input tokens request would use, without
+// sending it
+func (c *AnthropicClient) CountTokens(ctx context.Context, request *anthropic.AnthropicMessagesRequest) (*anthropic.TokenCount, error) {
+	body := countTokensRequest{
+		Model:      request.Model,
+		Messages:   request.Messages,
+		System:     request.System,
+		Thinking:   request.Thinking,
+		ToolChoice: request.ToolChoice,
+		Tools:      request.Tools,
+		MCPServers: request.MCPServers,
+	}
+	content, err := c.do(ctx, http.MethodPost, "/v1/messages/count_tokens", body, request.Betas)
+	if err != nil {
+		return nil, err
+	}
+	return decode[anthropic.TokenCount](content)
+}
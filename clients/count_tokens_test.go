@@ -0,0 +1,52 @@
+package clients_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/frozenkro/go-agent/clients/anthropictest"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func TestCountTokens(t *testing.T) {
+	srv := anthropictest.NewServer()
+	defer srv.Close()
+	var received *anthropictest.Request
+	srv.CountTokens = func(req *anthropictest.Request, body []byte) int {
+		received = req
+		return 42
+	}
+
+	temperature := 0.5
+	request := testRequest()
+	request.System = anthropic.NewSystemPrompt("Be brief")
+	request.Tools = []anthropic.AnthropicToolSpec{anthropic.NewCustomTool("get_weather", "Get the weather", map[string]any{"type": "object"})}
+	request.Temperature = &temperature
+	request.Stream = true
+	request.Betas = []string{"files-api-2025-04-14"}
+
+	count, err := testClient(srv).CountTokens(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.InputTokens != 42 {
+		t.Errorf("Expected 42 input tokens, got %v", count.InputTokens)
+	}
+	if received == nil {
+		t.Fatal("Expected a request to count_tokens")
+	}
+
+	// Only the fields that count towards input tokens are sent
+	keys := []string{}
+	for key := range received.Raw {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	if want := []string{"messages", "model", "system", "tools"}; !slices.Equal(keys, want) {
+		t.Errorf("Expected fields %v, got %v", want, keys)
+	}
+	if beta := received.Header.Get("anthropic-beta"); beta != "files-api-2025-04-14" {
+		t.Errorf("Expected the betas in the header, got %q", beta)
+	}
+}
@@ -0,0 +1,155 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+var filesBetas = []string{anthropic.BETA_FILES_API}
+
+type ListFilesParams struct {
+	Limit    int
+	AfterId  string
+	BeforeId string
+}
+
+// UploadFile stores the contents of r with the Files API under filename
+func (c *AnthropicClient) UploadFile(ctx context.Context, filename string, mediaType anthropic.MediaType, r io.Reader) (*anthropic.File, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%v"`, escapeQuotes(filename)))
+	header.Set("Content-Type", string(mediaType))
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	content, err := c.doRequest(ctx, apiRequest{
+		method:      http.MethodPost,
+		path:        "/v1/files",
+		body:        body.Bytes(),
+		contentType: form.FormDataContentType(),
+		betas:       filesBetas,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decode[anthropic.File](content)
+}
+
+// UploadFileFromPath uploads a local file, detecting its media type
+func (c *AnthropicClient) UploadFileFromPath(ctx context.Context, path string) (*anthropic.File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mediaType := anthropic.DetectMediaType(path, data)
+	return c.UploadFile(ctx, filepath.Base(path), mediaType, bytes.NewReader(data))
+}
+
+func (c *AnthropicClient) ListFiles(ctx context.Context, params ListFilesParams) (*anthropic.FileList, error) {
+	query := url.Values{}
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.AfterId != "" {
+		query.Set("after_id", params.AfterId)
+	}
+	if params.BeforeId != "" {
+		query.Set("before_id", params.BeforeId)
+	}
+
+	path := "/v1/files"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	content, err := c.do(ctx, http.MethodGet, path, nil, filesBetas)
+	if err != nil {
+		return nil, err
+	}
+	return decode[anthropic.FileList](content)
+}
+
+func (c *AnthropicClient) GetFileMetadata(ctx context.Context, fileId string) (*anthropic.File, error) {
+	content, err := c.do(ctx, http.MethodGet, "/v1/files/"+url.PathEscape(fileId), nil, filesBetas)
+	if err != nil {
+		return nil, err
+	}
+	return decode[anthropic.File](content)
+}
+
+// DownloadFile returns the contents of a file. Only files created by tools,
+// such as code execution outputs, can be downloaded.
+func (c *AnthropicClient) DownloadFile(ctx context.Context, fileId string) ([]byte, error) {
+	return c.doRequest(ctx, apiRequest{
+		method: http.MethodGet,
+		path:   "/v1/files/" + url.PathEscape(fileId) + "/content",
+		betas:  filesBetas,
+		raw:    true,
+	})
+}
+
+// DownloadFileTo saves a file into dir under its original name and returns
+// the path written
+func (c *AnthropicClient) DownloadFileTo(ctx context.Context, fileId string, dir string) (string, error) {
+	meta, err := c.GetFileMetadata(ctx, fileId)
+	if err != nil {
+		return "", err
+	}
+	data, err := c.DownloadFile(ctx, fileId)
+	if err != nil {
+		return "", err
+	}
+
+	// The name comes from the API, so keep it from escaping dir
+	name := filepath.Base(filepath.Clean("/" + meta.Filename))
+	if name == "/" || name == "." {
+		name = fileId
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (c *AnthropicClient) DeleteFile(ctx context.Context, fileId string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/v1/files/"+url.PathEscape(fileId), nil, filesBetas)
+	return err
+}
+
+func decode[T any](content []byte) (*T, error) {
+	v := new(T)
+	if err := json.Unmarshal(content, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func escapeQuotes(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
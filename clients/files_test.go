@@ -0,0 +1,87 @@
+package clients_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/frozenkro/go-agent/clients"
+	"github.com/frozenkro/go-agent/clients/anthropictest"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func TestFilesRoundTrip(t *testing.T) {
+	srv := anthropictest.NewServer()
+	defer srv.Close()
+	client := testClient(srv)
+	ctx := context.Background()
+
+	uploaded, err := client.UploadFile(ctx, "notes.txt", anthropic.MediaType("text/plain"), bytes.NewReader([]byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded.Filename != "notes.txt" || uploaded.SizeBytes != 5 || uploaded.Downloadable {
+		t.Errorf("Unexpected metadata for the uploaded file: %+v", uploaded)
+	}
+	output := srv.AddFile("chart.png", anthropic.MediaType("image/png"), []byte("png data"))
+
+	list, err := client.ListFiles(ctx, clients.ListFilesParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 2 || list.Data[0].Id != uploaded.Id || list.Data[1].Id != output.Id {
+		t.Errorf("Expected both files to be listed, got %+v", list.Data)
+	}
+
+	meta, err := client.GetFileMetadata(ctx, output.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Filename != "chart.png" || !meta.Downloadable {
+		t.Errorf("Unexpected metadata for the output file: %+v", meta)
+	}
+
+	// Only files created by tools can be downloaded
+	if _, err := client.DownloadFile(ctx, uploaded.Id); err == nil {
+		t.Errorf("Expected downloading an uploaded file to fail")
+	}
+	data, err := client.DownloadFile(ctx, output.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "png data" {
+		t.Errorf("Expected the file contents, got '%v'", data)
+	}
+	path, err := client.DownloadFileTo(ctx, output.Id, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved, err := os.ReadFile(path); err != nil || string(saved) != "png data" || filepath.Base(path) != "chart.png" {
+		t.Errorf("Expected chart.png to be saved, got %v with '%s' (%v)", path, saved, err)
+	}
+
+	if err := client.DeleteFile(ctx, uploaded.Id); err != nil {
+		t.Fatal(err)
+	}
+	list, err = client.ListFiles(ctx, clients.ListFilesParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 || list.Data[0].Id != output.Id {
+		t.Errorf("Expected only the output file after deleting, got %+v", list.Data)
+	}
+	_, err = client.GetFileMetadata(ctx, uploaded.Id)
+	var apiErr *clients.AnthropicError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a deleted file to be not found, got %v", err)
+	}
+
+	// Also fails if any request was sent without the Files API beta header
+	if err := srv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,60 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+type ListModelsParams struct {
+	Limit    int
+	AfterId  string
+	BeforeId string
+}
+
+func (c *AnthropicClient) ListModels(ctx context.Context, params ListModelsParams) (*anthropic.ModelList, error) {
+	query := url.Values{}
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.AfterId != "" {
+		query.Set("after_id", params.AfterId)
+	}
+	if params.BeforeId != "" {
+		query.Set("before_id", params.BeforeId)
+	}
+
+	path := "/v1/models"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	content, err := c.do(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decode[anthropic.ModelList](content)
+}
+
+// RefreshModels registers every model available to the API key with
+// registry and returns them. Capabilities of models the registry does not
+// know are copied from a known model of the same family.
+func (c *AnthropicClient) RefreshModels(ctx context.Context, registry *anthropic.ModelRegistry) ([]anthropic.ModelInfo, error) {
+	models := []anthropic.ModelInfo{}
+	params := ListModelsParams{Limit: 1000}
+	for {
+		list, err := c.ListModels(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range list.Data {
+			models = append(models, registry.Discover(m.Id, m.DisplayName))
+		}
+		if !list.HasMore || list.LastId == "" {
+			return models, nil
+		}
+		params.AfterId = list.LastId
+	}
+}
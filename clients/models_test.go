@@ -0,0 +1,59 @@
+package clients_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/frozenkro/go-agent/clients"
+	"github.com/frozenkro/go-agent/clients/anthropictest"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func TestRefreshModels(t *testing.T) {
+	srv := anthropictest.NewServer()
+	defer srv.Close()
+	srv.Models = []anthropic.ModelEntry{
+		{Id: anthropic.SONNET_4, Type: "model", DisplayName: "Claude Sonnet 4"},
+		{Id: "claude-sonnet-4-20991231", Type: "model", DisplayName: "Claude Sonnet 4 (next)"},
+		{Id: "claude-mystery-1-20991231", Type: "model", DisplayName: "Claude Mystery"},
+	}
+	registry := anthropic.NewModelRegistry(anthropic.KNOWN_MODELS...)
+
+	models, err := testClient(srv).RefreshModels(context.Background(), registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != len(srv.Models) {
+		t.Fatalf("Expected %v models, got %v", len(srv.Models), len(models))
+	}
+	for _, entry := range srv.Models {
+		if _, err := registry.Lookup(entry.Id); err != nil {
+			t.Errorf("Expected %v to be registered: %v", entry.Id, err)
+		}
+	}
+	if next, _ := registry.Lookup("claude-sonnet-4-20991231"); next.ContextWindow != 200000 {
+		t.Errorf("Expected the new snapshot to inherit the family's limits, got %+v", next)
+	}
+}
+
+func TestListModelsPages(t *testing.T) {
+	srv := anthropictest.NewServer()
+	defer srv.Close()
+	client := testClient(srv)
+
+	first, err := client.ListModels(context.Background(), clients.ListModelsParams{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Data) != 2 || !first.HasMore || first.LastId != string(anthropic.KNOWN_MODELS[1].Model) {
+		t.Fatalf("Expected the first 2 models with more to come, got %+v", first)
+	}
+
+	rest, err := client.ListModels(context.Background(), clients.ListModelsParams{AfterId: first.LastId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest.Data) != len(anthropic.KNOWN_MODELS)-2 || rest.HasMore || rest.FirstId != string(anthropic.KNOWN_MODELS[2].Model) {
+		t.Errorf("Expected the remaining models, got %+v", rest)
+	}
+}
@@ -0,0 +1,301 @@
+// Package replay provides an http.RoundTripper that records API interactions
+// to a fixture file and replays them offline, so agent runs can be tested
+// deterministically without network access or an API key.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type Mode string
+
+const (
+	// Requests are answered from the fixture file; unmatched requests fail
+	MODE_REPLAY Mode = "replay"
+	// Requests are forwarded to the real API and the fixture file is rewritten
+	MODE_RECORD Mode = "record"
+)
+
+const MODE_ENV string = "GA_REPLAY_MODE"
+
+// ModeFromEnv returns the mode set in GA_REPLAY_MODE, defaulting to replay
+func ModeFromEnv() Mode {
+	if Mode(os.Getenv(MODE_ENV)) == MODE_RECORD {
+		return MODE_RECORD
+	}
+	return MODE_REPLAY
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+}
+
+// Normalizer rewrites a decoded JSON request body before it is recorded or
+// matched, e.g. to drop fields that change between runs
+type Normalizer func(body any) any
+
+type Transport struct {
+	path        string
+	mode        Mode
+	next        http.RoundTripper
+	normalizers []Normalizer
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+type TransportOption func(*Transport)
+
+// WithNext sets the transport used to reach the real API when recording.
+// Defaults to http.DefaultTransport.
+func WithNext(next http.RoundTripper) TransportOption {
+	return func(t *Transport) {
+		t.next = next
+	}
+}
+
+func WithNormalizer(normalizer Normalizer) TransportOption {
+	return func(t *Transport) {
+		t.normalizers = append(t.normalizers, normalizer)
+	}
+}
+
+// RemoveKeys returns a Normalizer that deletes the given object keys at any
+// depth of the request body
+func RemoveKeys(keys ...string) Normalizer {
+	remove := make(map[string]bool)
+	for _, k := range keys {
+		remove[k] = true
+	}
+
+	var walk func(v any) any
+	walk = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				if remove[k] {
+					delete(v, k)
+				} else {
+					v[k] = walk(child)
+				}
+			}
+		case []any:
+			for i, child := range v {
+				v[i] = walk(child)
+			}
+		}
+		return v
+	}
+	return walk
+}
+
+// NewTransport creates a Transport backed by the fixture file at path. In
+// replay mode the file must exist; in record mode it is created or replaced.
+func NewTransport(path string, mode Mode, opts ...TransportOption) (*Transport, error) {
+	t := &Transport{
+		path: path,
+		mode: mode,
+		next: http.DefaultTransport,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if mode == MODE_REPLAY {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read replay fixture: %w", err)
+		}
+		if err := json.Unmarshal(data, &t.interactions); err != nil {
+			return nil, fmt.Errorf("Unable to parse replay fixture '%v': %w", path, err)
+		}
+		t.used = make([]bool, len(t.interactions))
+	}
+
+	return t, nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := []byte{}
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	recorded := RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.RequestURI(),
+		Body:   t.normalize(body),
+	}
+
+	if t.mode == MODE_RECORD {
+		return t.record(req, body, recorded)
+	}
+	return t.replay(req, recorded)
+}
+
+func (t *Transport) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, interaction := range t.interactions {
+		if t.used[i] || !matches(interaction.Request, recorded) {
+			continue
+		}
+		t.used[i] = true
+		return interaction.Response.toHttp(req), nil
+	}
+
+	return nil, fmt.Errorf("No recorded interaction in '%v' matches %v %v with body:\n%s", t.path, recorded.Method, recorded.Path, recorded.Body)
+}
+
+func (t *Transport) record(req *http.Request, body []byte, recorded RecordedRequest) (*http.Response, error) {
+	forwarded := req.Clone(req.Context())
+	forwarded.Body = io.NopCloser(bytes.NewReader(body))
+	forwarded.ContentLength = int64(len(body))
+
+	res, err := t.next.RoundTrip(forwarded)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.interactions = append(t.interactions, Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode:  res.StatusCode,
+			ContentType: res.Header.Get("content-type"),
+			Body:        string(resBody),
+		},
+	})
+	// Save after every interaction so a failing run still leaves a fixture
+	if err := t.save(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Unused returns the recorded interactions that were never replayed, which
+// usually means the code under test made fewer requests than when recorded
+func (t *Transport) Unused() []Interaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	unused := []Interaction{}
+	for i, interaction := range t.interactions {
+		if i < len(t.used) && !t.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+func (t *Transport) save() error {
+	data, err := json.MarshalIndent(t.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(t.path, data, 0644)
+}
+
+// normalize canonicalizes a JSON body so semantically equal requests compare
+// equal regardless of key order or whitespace. Non-JSON bodies are stored as
+// JSON strings.
+func (t *Transport) normalize(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		quoted, _ := json.Marshal(string(body))
+		return quoted
+	}
+	for _, n := range t.normalizers {
+		decoded = n(decoded)
+	}
+
+	canonical, err := json.Marshal(decoded)
+	if err != nil {
+		quoted, _ := json.Marshal(string(body))
+		return quoted
+	}
+	return canonical
+}
+
+func matches(a RecordedRequest, b RecordedRequest) bool {
+	if a.Method != b.Method || a.Path != b.Path {
+		return false
+	}
+	return bytes.Equal(canonicalize(a.Body), canonicalize(b.Body))
+}
+
+// canonicalize re-encodes a fixture body, which may have been edited or
+// indented by hand, into compact form with sorted keys
+func canonicalize(body json.RawMessage) []byte {
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return body
+	}
+	canonical, err := json.Marshal(decoded)
+	if err != nil {
+		return body
+	}
+	return canonical
+}
+
+func (r RecordedResponse) toHttp(req *http.Request) *http.Response {
+	header := http.Header{}
+	if r.ContentType != "" {
+		header.Set("content-type", r.ContentType)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %v", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(r.Body))),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,144 @@
+package replay_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frozenkro/go-agent/clients/replay"
+)
+
+// fixture is indented, with keys in a different order than requests send them
+const fixture = `[
+  {
+    "request": {
+      "method": "POST",
+      "path": "/v1/messages",
+      "body": {
+        "messages": [ { "role": "user", "content": "Hi" } ],
+        "max_tokens": 100,
+        "model": "claude-sonnet-4-20250514"
+      }
+    },
+    "response": { "status_code": 200, "content_type": "application/json", "body": "{\"id\":\"msg_1\"}" }
+  }
+]`
+
+func writeFixture(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func post(t *testing.T, transport http.RoundTripper, body string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "https://api.anthropic.com/v1/messages", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transport.RoundTrip(req)
+}
+
+func TestReplayMatchesNormalizedBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		normalizers []replay.Normalizer
+		match       bool
+	}{
+		{
+			name:  "same order",
+			body:  `{"messages":[{"role":"user","content":"Hi"}],"max_tokens":100,"model":"claude-sonnet-4-20250514"}`,
+			match: true,
+		},
+		{
+			name:  "reordered keys",
+			body:  `{"model":"claude-sonnet-4-20250514","max_tokens":100,"messages":[{"content":"Hi","role":"user"}]}`,
+			match: true,
+		},
+		{
+			name:  "whitespace and escapes",
+			body:  "{\n  \"model\": \"claude-sonnet-4-20250514\",\n  \"max_tokens\": 1e2,\n  \"messages\": [{\"role\": \"user\", \"content\": \"\\u0048i\"}]\n}",
+			match: true,
+		},
+		{
+			name:        "removed keys",
+			body:        `{"model":"claude-sonnet-4-20250514","max_tokens":100,"metadata":{"user_id":"abc"},"messages":[{"role":"user","content":"Hi"}]}`,
+			normalizers: []replay.Normalizer{replay.RemoveKeys("metadata")},
+			match:       true,
+		},
+		{
+			name:  "different content",
+			body:  `{"model":"claude-sonnet-4-20250514","max_tokens":100,"messages":[{"role":"user","content":"Hello"}]}`,
+			match: false,
+		},
+		{
+			name:  "reordered messages",
+			body:  `{"model":"claude-sonnet-4-20250514","max_tokens":100,"messages":[{"role":"user","content":"Hi"},{"role":"user","content":"Hi"}]}`,
+			match: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []replay.TransportOption{}
+			for _, n := range tt.normalizers {
+				opts = append(opts, replay.WithNormalizer(n))
+			}
+			transport, err := replay.NewTransport(writeFixture(t, fixture), replay.MODE_REPLAY, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := post(t, transport, tt.body)
+			if tt.match && err != nil {
+				t.Fatalf("Expected the request to match, got %v", err)
+			}
+			if !tt.match && err == nil {
+				t.Fatalf("Expected no match, got status %v", res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("content-type", "application/json")
+		w.Write([]byte(`{"echo":` + string(body) + `}`))
+	}))
+	defer api.Close()
+	path := filepath.Join(t.TempDir(), "recorded.json")
+
+	recorder, err := replay.NewTransport(path, replay.MODE_RECORD, replay.WithNext(api.Client().Transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Recording forwards the request to its own host, so send it to the stub
+	req, _ := http.NewRequest(http.MethodPost, api.URL+"/v1/messages", strings.NewReader(`{"b":[1,2],"a":"x"}`))
+	if _, err := recorder.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	player, err := replay.NewTransport(path, replay.MODE_REPLAY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := post(t, player, `{ "a": "x", "b": [1, 2] }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	if string(body) != `{"echo":{"b":[1,2],"a":"x"}}` {
+		t.Errorf("Expected the recorded response, got %s", body)
+	}
+	if unused := player.Unused(); len(unused) > 0 {
+		t.Errorf("Expected every interaction to be replayed, %v were not", len(unused))
+	}
+}
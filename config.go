@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DEFAULT_CONFIG_FILE is read from the current directory when -config is not given
+const DEFAULT_CONFIG_FILE = ".go-agent.json"
+
+// DEFAULT_CONFIG_KEYS are the only flags DEFAULT_CONFIG_FILE may set. A file
+// found in the current directory may come from a cloned repository, so it
+// must not reach flags that run commands, read or upload files, or loosen
+// the sandbox.
+var DEFAULT_CONFIG_KEYS = []string{"model", "max-tokens", "temperature", "top-p", "top-k", "stop", "service-tier", "user-id"}
+
+// applyConfig sets the flags named by the keys of a JSON config file, e.g.
+// {"model": "claude-sonnet-4-5-20250929", "temperature": 0.2, "stop": ["END"]}.
+// Flags given on the command line take precedence, and list values set
+// repeatable flags once per element. An explicit file, given with -config,
+// must exist and may set any flag; otherwise a missing file is ignored and
+// only DEFAULT_CONFIG_KEYS may be set.
+func applyConfig(flags *flag.FlagSet, path string, explicit bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	config := map[string]any{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("Invalid config file '%v': %w", path, err)
+	}
+	for name := range config {
+		if !explicit && !slices.Contains(DEFAULT_CONFIG_KEYS, name) {
+			return fmt.Errorf("Setting '%v' in '%v' is not allowed. Only %v can be set there; pass the file with -config to set other flags",
+				name, path, strings.Join(DEFAULT_CONFIG_KEYS, ", "))
+		}
+	}
+
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for name, value := range config {
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("Unknown setting '%v' in config file '%v'", name, path)
+		}
+		if set[name] {
+			continue
+		}
+
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		for _, v := range values {
+			if err := flags.Set(name, configValue(v)); err != nil {
+				return fmt.Errorf("Invalid value for '%v' in config file '%v': %w", name, path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func configValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// setOptionalFloat returns a flag.Func handler that parses the flag into a
+// float, leaving dst nil unless the flag is given
+func setOptionalFloat(dst **float64) func(string) error {
+	return func(s string) error {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		*dst = &f
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApplyConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		explicit bool
+		args     []string
+		model    string
+		sandbox  bool
+		stop     []string
+		temp     string
+		err      bool
+	}{
+		{name: "missing file", model: "default"},
+		{name: "missing explicit file", explicit: true, err: true},
+		{name: "invalid json", config: `{"model": `, err: true},
+		{name: "allowed keys", config: `{"model": "haiku", "temperature": 0.2}`, model: "haiku", temp: "0.2"},
+		{name: "disallowed key", config: `{"model": "haiku", "sandbox": true}`, err: true},
+		{name: "disallowed key when explicit", config: `{"sandbox": true}`, explicit: true, model: "default", sandbox: true},
+		{name: "unknown setting", config: `{"colour": "red"}`, explicit: true, err: true},
+		{name: "command line takes precedence", config: `{"model": "haiku"}`, args: []string{"-model", "opus"}, model: "opus"},
+		{name: "list values", config: `{"stop": ["END", "STOP"]}`, model: "default", stop: []string{"END", "STOP"}},
+		{name: "float formatting", config: `{"temperature": 1e-1}`, model: "default", temp: "0.1"},
+		{name: "invalid value", config: `{"temperature": "warm"}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), DEFAULT_CONFIG_FILE)
+			if tt.config != "" {
+				if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			flags := flag.NewFlagSet("go-agent", flag.ContinueOnError)
+			flags.SetOutput(io.Discard)
+			model := flags.String("model", "default", "")
+			sandbox := flags.Bool("sandbox", false, "")
+			var stop []string
+			flags.Func("stop", "", func(s string) error {
+				stop = append(stop, s)
+				return nil
+			})
+			var temperature *float64
+			flags.Func("temperature", "", setOptionalFloat(&temperature))
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyConfig(flags, path, tt.explicit)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if *model != tt.model {
+				t.Errorf("Expected model %q, got %q", tt.model, *model)
+			}
+			if *sandbox != tt.sandbox {
+				t.Errorf("Expected sandbox %v, got %v", tt.sandbox, *sandbox)
+			}
+			if !reflect.DeepEqual(stop, tt.stop) {
+				t.Errorf("Expected stop sequences %q, got %q", tt.stop, stop)
+			}
+			temp := ""
+			if temperature != nil {
+				temp = configValue(*temperature)
+			}
+			if temp != tt.temp {
+				t.Errorf("Expected temperature %q, got %q", tt.temp, temp)
+			}
+		})
+	}
+}
@@ -3,11 +3,10 @@ module github.com/frozenkro/go-agent
 go 1.24.6
 
 require (
+	github.com/creack/pty v1.1.24
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
+	github.com/mitchellh/mapstructure v1.5.0
 )
 
-require (
-	github.com/creack/pty v1.1.24 // indirect
-	github.com/mitchellh/mapstructure v1.5.0 // indirect
-)
+require golang.org/x/sys v0.30.0
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/creack/pty"
@@ -19,17 +20,53 @@ const (
 	BUFFER_POLL_RATE time.Duration = time.Millisecond * 10
 )
 
+const DEFAULT_SESSION string = "default"
+
 type BashTool struct {
-	bs *BashSession
+	mu       sync.Mutex
+	sessions map[string]*BashSession
+	jobs     map[string]*Job
+	jobCount int
+	opts     []BashSessionOption
 }
 
 type BashSession struct {
-	tty            tty
-	prompt         string
+	// execMu serializes commands, whose input and output would otherwise
+	// interleave on the tty
+	execMu         sync.Mutex
+	tty            TTY
+	newTTY         func() (TTY, error)
+	pid            int
+	sessionId      uuid.UUID
+	lastExitCode   int
+	exited         bool
+	outputHandler  func(string)
 	defaultTimeout time.Duration
+	sandbox        *SandboxConfig
+	limits         *ResourceLimits
+	cgroup         string
+	shellExited    chan struct{}
+	shellState     *os.ProcessState
+	cgroupEvents   map[string]int
+	outputLimit    int
+	spillDir       string
+	spillMu        sync.Mutex
+	spillFiles     []string
+	rawOutput      bool
+	workingDir     string
+	env            []string
+	envAllow       []string
+	envDeny        []string
+	shell          string
+	initScript     string
+	rows           uint16
+	cols           uint16
 }
 
-type tty interface {
+// TTY is the terminal a BashSession talks to, normally the master side of
+// the shell's pty. Reads must honour the deadline set with SetReadDeadline by
+// returning os.ErrDeadlineExceeded.
+type TTY interface {
 	Write([]byte) (int, error)
 	Read([]byte) (int, error)
 	SetReadDeadline(time.Time) error
@@ -38,132 +75,290 @@ type tty interface {
 
 type BashSessionOption func(*BashSession)
 
+// WithTTY connects the session to terminals created by newTTY instead of
+// starting a shell, e.g. a bashtest.FakeTerminal. newTTY is called again
+// whenever the session is restarted.
+func WithTTY(newTTY func() (TTY, error)) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.newTTY = newTTY
+	}
+}
+
 func WithTimeout(timeout time.Duration) BashSessionOption {
 	return func(bs *BashSession) {
 		bs.defaultTimeout = timeout
 	}
 }
 
-func NewBashSession(opts ...BashSessionOption) (*BashSession, error) {
-	cmd := exec.Command("bash", "--norc", "--noprofile", "-i")
-
-	f, err := pty.Start(cmd)
-	if err != nil {
-		return nil, err
+func NewBashTool(opts ...BashSessionOption) *BashTool {
+	return &BashTool{
+		sessions: make(map[string]*BashSession),
+		jobs:     make(map[string]*Job),
+		opts:     opts,
 	}
+}
 
-	sessionId := uuid.New()
-	prompt := fmt.Sprintf("__READY_%v__", sessionId)
-
+func NewBashSession(opts ...BashSessionOption) (*BashSession, error) {
 	defaultTimeout, err := time.ParseDuration("1m")
 	if err != nil {
 		return nil, err
 	}
 
 	bs := &BashSession{
-		tty:            f,
-		prompt:         prompt,
+		sessionId:      uuid.New(),
+		lastExitCode:   EXIT_UNKNOWN,
 		defaultTimeout: defaultTimeout,
+		outputLimit:    DEFAULT_OUTPUT_LIMIT,
+		shell:          "bash",
+		rows:           DEFAULT_ROWS,
+		cols:           DEFAULT_COLS,
 	}
 	for _, opt := range opts {
 		opt(bs)
 	}
 
-	command := fmt.Sprintf("PS1=%v", prompt)
-	bs.Execute(command)
+	if bs.newTTY != nil {
+		if bs.tty, err = bs.newTTY(); err != nil {
+			return nil, err
+		}
+	} else if err := bs.startShell(); err != nil {
+		return nil, err
+	}
+
+	// Setup output is not part of any command, so it is not streamed
+	outputHandler := bs.outputHandler
+	bs.outputHandler = nil
+
+	if _, err := bs.Execute(bs.promptSetup()); err != nil {
+		bs.Deinit()
+		return nil, fmt.Errorf("Error configuring shell prompt: %w", err)
+	}
+
+	if bs.initScript != "" {
+		if _, err := bs.Execute(fmt.Sprintf(". '%v'", strings.ReplaceAll(bs.initScript, "'", `'\''`))); err != nil {
+			bs.Deinit()
+			return nil, fmt.Errorf("Error running init script '%v': %w", bs.initScript, err)
+		}
+	}
+	bs.outputHandler = outputHandler
 
 	return bs, nil
 }
 
-func (bs *BashSession) ExecuteWithTimeout(command string, timeout time.Duration) (string, error) {
-	bs.sendCommand(command)
+func (bs *BashSession) startShell() error {
+	var err error
+	cmd := exec.Command(bs.shell, shellArgs(bs.shell)...)
+	cmd.Env = bs.environment(os.Environ())
+	cmd.Dir = bs.workingDir
+	if bs.sandbox != nil {
+		cmd, err = sandboxCommand(cmd, *bs.sandbox)
+		if err != nil {
+			return err
+		}
+	}
 
-	done := make(chan string, 1)
-	errChan := make(chan error, 1)
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		return err
+	}
+	f, err := pollable(ptmx)
+	if err != nil {
+		ptmx.Close()
+		return err
+	}
+	bs.tty = f
 
+	if err := setWindowSize(f, bs.rows, bs.cols); err != nil {
+		bs.Deinit()
+		return err
+	}
+	bs.pid = cmd.Process.Pid
+	bs.shellExited = make(chan struct{})
 	go func() {
-		output, err := bs.getResponse(command)
-		if err != nil {
-			errChan <- err
-		} else {
-			done <- output
-		}
+		cmd.Wait()
+		bs.shellState = cmd.ProcessState
+		close(bs.shellExited)
 	}()
 
-	select {
-	case result := <-done:
-		return strings.TrimRight(result, "\n"), nil
-	case err := <-errChan:
+	if err := bs.applyLimits(bs.pid); err != nil {
+		bs.Deinit()
+		return err
+	}
+	return nil
+}
+
+func (bs *BashSession) ExecuteWithTimeout(command string, timeout time.Duration) (string, error) {
+	bs.execMu.Lock()
+	defer bs.execMu.Unlock()
+	return bs.execute(command, timeout)
+}
+
+func (bs *BashSession) execute(command string, timeout time.Duration) (string, error) {
+	if err := bs.armCPULimit(); err != nil {
 		return "", err
-	case <-time.After(timeout):
-		return "", fmt.Errorf("Command timed out after %v", timeout.String())
 	}
+	input := wrapCommand(command)
+	bs.sendCommand(input)
+
+	result, err := bs.getResponse(command, strings.Count(input, "\n"), timeout)
+	if err != nil {
+		return "", err
+	}
+
+	result = strings.TrimRight(result, "\n")
+	return bs.truncateOutput(result) + bs.limitViolations(), nil
+}
+
+// LastExitCode returns the exit status of the last command, or EXIT_UNKNOWN
+// if the shell does not report it
+func (bs *BashSession) LastExitCode() int {
+	return bs.lastExitCode
 }
 
 func (bs *BashSession) Execute(command string) (string, error) {
 	return bs.ExecuteWithTimeout(command, bs.defaultTimeout)
 }
 
-func (t BashTool) Invoke(params any) (string, error) {
+func (t *BashTool) Invoke(params any) (string, error) {
 	var p toolschema.BashToolInput
 	err := mapstructure.Decode(params, &p)
 	if err != nil {
 		return "", fmt.Errorf("Unable to parse invoke params for BashTool: '%v'", params)
 	}
 
-	if t.bs != nil && p.Restart {
-		t.bs.Deinit()
+	return t.executeInSession(DEFAULT_SESSION, p.Command, p.Restart)
+}
+
+func (t *BashTool) executeInSession(name string, command string, restart bool) (string, error) {
+	bs, err := t.session(name, restart)
+	if err != nil {
+		return "", err
+	}
+
+	if command == "" {
+		return "", nil
+	}
+
+	// The exit status is read before another command can replace it
+	bs.execMu.Lock()
+	output, err := bs.execute(command, bs.defaultTimeout)
+	code := bs.lastExitCode
+	bs.execMu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	if code != 0 && code != EXIT_UNKNOWN {
+		output = strings.TrimLeft(fmt.Sprintf("%v\n[exit status %v]", output, code), "\n")
+	}
+	return output, nil
+}
+
+// session returns the named session, starting it if it does not exist yet or
+// restart is requested
+func (t *BashTool) session(name string, restart bool) (*BashSession, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bs, ok := t.sessions[name]
+	restart = restart || (ok && bs.exited)
+	if ok && restart {
+		if err := bs.Deinit(); err != nil {
+			delete(t.sessions, name)
+			return nil, fmt.Errorf("Error ending Bash Session: %w", err)
+		}
 	}
 
-	if t.bs == nil || p.Restart {
+	if !ok || restart {
 		var err error
-		t.bs, err = NewBashSession()
+		bs, err = NewBashSession(t.opts...)
 
 		if err != nil {
-			return "", fmt.Errorf("Error initializing Bash Session: %w", err)
+			delete(t.sessions, name)
+			return nil, fmt.Errorf("Error initializing Bash Session: %w", err)
 		}
+		t.sessions[name] = bs
 	}
 
-	if p.Command != "" {
-		return t.bs.Execute(p.Command)
-	}
+	return bs, nil
+}
+
+// Close ends every session and kills every background job started by the tool
+func (t *BashTool) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	return "", nil
+	errs := []error{}
+	for name, bs := range t.sessions {
+		errs = append(errs, bs.Deinit())
+		delete(t.sessions, name)
+	}
+	for name, job := range t.jobs {
+		errs = append(errs, job.Kill())
+		delete(t.jobs, name)
+	}
+	return errors.Join(errs...)
 }
 
-func (bs *BashSession) getResponse(command string) (string, error) {
+// getResponse reads output until the shell reports the command finished. It
+// interrupts the command and returns an error if the shell is left waiting
+// for more input, a program is waiting for interactive input, or the timeout
+// expires. continuations is the number of continuation prompts the command's
+// own newlines are expected to produce.
+func (bs *BashSession) getResponse(command string, continuations int, timeout time.Duration) (string, error) {
 
 	buffer := make([]byte, BUFFER_SIZE)
-	accumulated := ""
+	var accumulated strings.Builder
+	scanner := promptScanner{bs: bs}
+	stream := outputStream{bs: bs}
+
+	start := time.Now()
+	lastOutput := start
+	lastInputCheck := start
 
 	for {
 		iterationTime := time.Now()
 		bs.tty.SetReadDeadline(iterationTime.Add(BUFFER_POLL_RATE))
 		n, err := bs.tty.Read(buffer)
 		if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
-			return "", err
+			bs.exited = true
+			return "", fmt.Errorf("The shell exited and must be restarted (%w)%v%v", err, partialOutput(bs, accumulated.String(), command), bs.shellViolation())
 		}
 
 		if n > 0 {
-			chunk := string(buffer[:n])
-			accumulated += chunk
+			accumulated.Write(buffer[:n])
+			stream.write(buffer[:n])
+			lastOutput = time.Now()
 		}
 
-		if bs.isAtPrompt(accumulated) {
-			result := strings.TrimPrefix(strings.TrimSuffix(strings.TrimSpace(accumulated), bs.prompt), command)
-			return result, nil
-		} else {
-			time.Sleep(BUFFER_POLL_RATE)
+		output := accumulated.String()
+		if done, exitCode := scanner.scan(output); done {
+			bs.lastExitCode = exitCode
+			return bs.cleanOutput(output, command), nil
 		}
 
-	}
-}
+		if scanner.continuations > continuations {
+			bs.interrupt()
+			return "", fmt.Errorf("Command is incomplete and the shell is waiting for more input. Check for unbalanced quotes or brackets, or an unterminated heredoc. The command was cancelled")
+		}
 
-func (bs *BashSession) isAtPrompt(accumulated string) bool {
-	cleanStr := strings.TrimSpace(accumulated)
-	endsInPrompt := strings.HasSuffix(cleanStr, bs.prompt)
-	endsInPromptInit := strings.HasSuffix(cleanStr, fmt.Sprintf("PS1=%v", bs.prompt))
-	return endsInPrompt && !endsInPromptInit
+		if time.Since(start) > timeout {
+			bs.interrupt()
+			return "", fmt.Errorf("Command timed out after %v and was interrupted%v", timeout.String(), partialOutput(bs, output, command))
+		}
+
+		if time.Since(lastOutput) > INTERACTIVE_IDLE && time.Since(lastInputCheck) > INTERACTIVE_IDLE {
+			lastInputCheck = time.Now()
+			if program, waiting := bs.waitingForInput(); waiting {
+				bs.interrupt()
+				return "", fmt.Errorf("Command appears to be waiting for interactive input from '%v' and was interrupted. "+
+					"Use non-interactive flags or pipe input to the command, or start it with the bash_job tool to send it input%v", program, partialOutput(bs, output, command))
+			}
+		}
+
+		if n == 0 {
+			time.Sleep(BUFFER_POLL_RATE)
+		}
+	}
 }
 
 func (bs *BashSession) sendCommand(c string) {
@@ -171,7 +366,14 @@ func (bs *BashSession) sendCommand(c string) {
 	bs.tty.Write([]byte("\n"))
 }
 
-func (bs *BashSession) Deinit() {
+// Deinit ends the shell and removes the session's cgroup and saved outputs,
+// returning an error if they could not be removed
+func (bs *BashSession) Deinit() error {
+	return errors.Join(bs.endShell(), bs.removeSpillFiles())
+}
+
+// endShell ends the shell and removes its cgroup, keeping saved outputs
+func (bs *BashSession) endShell() error {
 	if bs.tty != nil {
 		// First write an EOT to indicate end of `bash` command
 		bs.tty.Write([]byte{4})
@@ -180,30 +382,5 @@ func (bs *BashSession) Deinit() {
 		// Close file descriptor for character device
 		bs.tty.Close()
 	}
+	return bs.removeCgroup()
 }
-
-// TODO Repurpose original implementation into tests
-// func main() {
-// 	c := exec.Command("bash", "-i")
-// 	f, err := pty.Start(c)
-// 	if err != nil {
-// 		panic(err)
-// 	}
-
-// 	command := fmt.Sprintf("PS1='%v'", PROMPT)
-// 	sendCommand(f, command)
-// 	fmt.Print(getResponse(f, command))
-
-// 	command = "ls -al"
-// 	sendCommand(f, command)
-// 	fmt.Print(getResponse(f, command))
-
-// 	command = "pwd"
-// 	sendCommand(f, command)
-// 	fmt.Print(getResponse(f, command))
-
-// 	f.Write([]byte{4}) // EOT
-
-// 	fmt.Println("\nCompleted..")
-
-// }
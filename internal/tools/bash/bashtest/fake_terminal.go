@@ -0,0 +1,262 @@
+// Package bashtest provides a scripted fake terminal for BashSession and a
+// suite of checks that can be run against it or against a real shell.
+package bashtest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frozenkro/go-agent/internal/tools/bash"
+)
+
+// Response is the scripted reaction of a FakeTerminal to a command
+type Response struct {
+	Output   string
+	ExitCode int
+	// Delay before the output is written
+	Delay time.Duration
+	// Hang leaves the command running until it is interrupted
+	Hang bool
+	// Incomplete makes the shell wait for more input, as after an unbalanced quote
+	Incomplete bool
+	// Exit ends the shell after the output is written
+	Exit bool
+}
+
+// FakeTerminal is a TTY that emulates just enough of an interactive shell
+// using the BashSession prompt protocol: it answers each command with its
+// scripted response followed by the session's prompt marker.
+type FakeTerminal struct {
+	// Echo writes input back to the reader, as a terminal with echo enabled
+	// does. The commands "stty echo" and "stty -echo" turn it on and off.
+	Echo bool
+	// Fallback answers commands without a scripted response. By default they
+	// fail with exit status 127.
+	Fallback func(command string) Response
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	responses map[string]Response
+	commands  []string
+	output    []byte
+	input     string
+	pending   []string
+	sessionId string
+	running   bool
+	deadline  time.Time
+	timer     *time.Timer
+	closed    bool
+}
+
+var sessionIdPattern = regexp.MustCompile(`go-agent;([0-9a-f-]+);done`)
+
+func NewFakeTerminal() *FakeTerminal {
+	f := &FakeTerminal{
+		responses: make(map[string]Response),
+	}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// On scripts the response to command
+func (f *FakeTerminal) On(command string, response Response) *FakeTerminal {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[command] = response
+	return f
+}
+
+// Commands returns the commands received so far, excluding session setup
+func (f *FakeTerminal) Commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.commands...)
+}
+
+// Factory returns a function for bash.WithTTY that always returns f
+func (f *FakeTerminal) Factory() func() (bash.TTY, error) {
+	return func() (bash.TTY, error) {
+		return f, nil
+	}
+}
+
+func (f *FakeTerminal) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.Echo {
+		f.writeOutput(strings.ReplaceAll(string(p), "\n", "\r\n"))
+	}
+
+	for _, b := range p {
+		switch b {
+		case 3:
+			f.interrupt()
+		case 4:
+			f.closed = true
+			f.cond.Broadcast()
+		case '\n':
+			line := f.input
+			f.input = ""
+			f.receiveLine(line)
+		default:
+			f.input += string(b)
+		}
+	}
+	return len(p), nil
+}
+
+func (f *FakeTerminal) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.output) == 0 {
+		if f.closed {
+			return 0, io.EOF
+		}
+		if !f.deadline.IsZero() && !time.Now().Before(f.deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		f.cond.Wait()
+	}
+
+	n := copy(p, f.output)
+	f.output = f.output[n:]
+	return n, nil
+}
+
+func (f *FakeTerminal) SetReadDeadline(t time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.deadline = t
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	if !t.IsZero() {
+		f.timer = time.AfterFunc(time.Until(t), func() {
+			f.mu.Lock()
+			f.cond.Broadcast()
+			f.mu.Unlock()
+		})
+	}
+	return nil
+}
+
+func (f *FakeTerminal) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	f.cond.Broadcast()
+	return nil
+}
+
+// receiveLine handles a complete line of input. Multi-line commands arrive
+// wrapped in "{" and "}" lines and are collected before they run.
+func (f *FakeTerminal) receiveLine(line string) {
+	if f.sessionId == "" {
+		if m := sessionIdPattern.FindStringSubmatch(line); m != nil {
+			f.sessionId = m[1]
+			f.prompt(0)
+		}
+		return
+	}
+
+	if len(f.pending) > 0 || line == "{" {
+		f.pending = append(f.pending, line)
+		if line != "}" {
+			f.continuation()
+			return
+		}
+		lines := f.pending[1 : len(f.pending)-1]
+		f.pending = nil
+		f.run(strings.Join(lines, "\n"))
+		return
+	}
+
+	f.run(line)
+}
+
+func (f *FakeTerminal) run(command string) {
+	f.commands = append(f.commands, command)
+
+	response, ok := f.responses[command]
+	switch command {
+	case "stty echo", "stty -echo":
+		f.Echo = command == "stty echo"
+		ok = true
+	}
+	if !ok {
+		if f.Fallback != nil {
+			response = f.Fallback(command)
+		} else {
+			response = Response{Output: fmt.Sprintf("bash: %v: command not found\n", command), ExitCode: 127}
+		}
+	}
+
+	if response.Incomplete {
+		f.continuation()
+		f.running = true
+		return
+	}
+
+	f.running = true
+	if response.Hang {
+		return
+	}
+
+	finish := func() {
+		if !f.running || f.closed {
+			return
+		}
+		f.writeOutput(strings.ReplaceAll(response.Output, "\n", "\r\n"))
+		f.running = false
+		if response.Exit {
+			f.closed = true
+			return
+		}
+		f.prompt(response.ExitCode)
+	}
+	if response.Delay > 0 {
+		time.AfterFunc(response.Delay, func() {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			finish()
+		})
+		return
+	}
+	finish()
+}
+
+// interrupt emulates Ctrl-C: the running command or partial input is dropped
+// and the shell prints a new prompt
+func (f *FakeTerminal) interrupt() {
+	f.input = ""
+	f.pending = nil
+	if f.running {
+		f.writeOutput("^C\r\n")
+		f.running = false
+	}
+	f.prompt(130)
+}
+
+func (f *FakeTerminal) prompt(exitCode int) {
+	f.writeOutput(fmt.Sprintf("%v%v;done;%v%v", bash.MARKER_PREFIX, f.sessionId, exitCode, bash.MARKER_SUFFIX))
+}
+
+func (f *FakeTerminal) continuation() {
+	f.writeOutput(fmt.Sprintf("%v%v;cont%v", bash.MARKER_PREFIX, f.sessionId, bash.MARKER_SUFFIX))
+}
+
+func (f *FakeTerminal) writeOutput(s string) {
+	f.output = append(f.output, s...)
+	f.cond.Broadcast()
+}
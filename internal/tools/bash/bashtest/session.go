@@ -0,0 +1,203 @@
+package bashtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frozenkro/go-agent/internal/tools/bash"
+)
+
+// check is a single scenario run by CheckSession
+type check struct {
+	name string
+	run  func(opts []bash.BashSessionOption) error
+}
+
+var checks = []check{
+	{"output", checkOutput},
+	{"exit status", checkExitStatus},
+	{"heredoc", checkHeredoc},
+	{"echo", checkEcho},
+	{"custom prompt", checkCustomPrompt},
+	{"incomplete command", checkIncomplete},
+	{"timeout", checkTimeout},
+	{"truncation", checkTruncation},
+	{"restart", checkRestart},
+}
+
+// CheckSession runs BashSessions created with opts through a series of
+// command-level scenarios, each as a subtest of t: output capture, exit
+// status, multi-line input, echo stripping, commands that change the prompt,
+// recovery from incomplete commands and timeouts, output truncation and
+// restarting an exited shell.
+//
+// With no opts the checks run against a real bash. ScriptedTTY provides a
+// fake terminal scripted for every scenario:
+//
+//	bashtest.CheckSession(t, bash.WithTTY(bashtest.ScriptedTTY()))
+func CheckSession(t *testing.T, opts ...bash.BashSessionOption) {
+	t.Helper()
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.run(opts); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// ScriptedTTY returns a TTY factory for bash.WithTTY whose fake terminals
+// answer the commands used by CheckSession like bash would
+func ScriptedTTY() func() (bash.TTY, error) {
+	return func() (bash.TTY, error) {
+		var seq strings.Builder
+		for i := 1; i <= 2000; i++ {
+			seq.WriteString(strconv.Itoa(i) + "\n")
+		}
+
+		f := NewFakeTerminal().
+			On("echo hello", Response{Output: "hello\n"}).
+			On("(exit 3)", Response{ExitCode: 3}).
+			On("cat <<EOF\nline one\nline two\nEOF", Response{Output: "line one\nline two\n"}).
+			On("PS1='custom> '; PROMPT_COMMAND='echo custom'", Response{Output: "bash: PROMPT_COMMAND: readonly variable\n", ExitCode: 1}).
+			On(`echo "unterminated`, Response{Incomplete: true}).
+			On("echo recovered", Response{Output: "recovered\n"}).
+			On("sleep 5", Response{Hang: true}).
+			On("seq 1 2000", Response{Output: seq.String()}).
+			On("export GA_CHECK=1", Response{}).
+			On(`echo "${GA_CHECK:-unset}"`, Response{Output: "unset\n"}).
+			On("exit", Response{Output: "exit\n", Exit: true})
+		return f, nil
+	}
+}
+
+func withSession(opts []bash.BashSessionOption, fn func(bs *bash.BashSession) error) error {
+	bs, err := bash.NewBashSession(opts...)
+	if err != nil {
+		return err
+	}
+	defer bs.Deinit()
+	return fn(bs)
+}
+
+func expectOutput(bs *bash.BashSession, command string, want string) error {
+	got, err := bs.Execute(command)
+	if err != nil {
+		return fmt.Errorf("%q failed: %w", command, err)
+	}
+	if got != want {
+		return fmt.Errorf("%q returned %q, want %q", command, got, want)
+	}
+	return nil
+}
+
+func checkOutput(opts []bash.BashSessionOption) error {
+	return withSession(opts, func(bs *bash.BashSession) error {
+		if err := expectOutput(bs, "echo hello", "hello"); err != nil {
+			return err
+		}
+		if code := bs.LastExitCode(); code != 0 {
+			return fmt.Errorf("exit status is %v, want 0", code)
+		}
+		return nil
+	})
+}
+
+func checkExitStatus(opts []bash.BashSessionOption) error {
+	return withSession(opts, func(bs *bash.BashSession) error {
+		if err := expectOutput(bs, "(exit 3)", ""); err != nil {
+			return err
+		}
+		if code := bs.LastExitCode(); code != 3 {
+			return fmt.Errorf("exit status is %v, want 3", code)
+		}
+		return nil
+	})
+}
+
+func checkHeredoc(opts []bash.BashSessionOption) error {
+	return withSession(opts, func(bs *bash.BashSession) error {
+		return expectOutput(bs, "cat <<EOF\nline one\nline two\nEOF", "line one\nline two")
+	})
+}
+
+func checkEcho(opts []bash.BashSessionOption) error {
+	return withSession(opts, func(bs *bash.BashSession) error {
+		if err := expectOutput(bs, "stty echo", ""); err != nil {
+			return err
+		}
+		return expectOutput(bs, "echo hello", "hello")
+	})
+}
+
+func checkCustomPrompt(opts []bash.BashSessionOption) error {
+	return withSession(opts, func(bs *bash.BashSession) error {
+		if _, err := bs.Execute("PS1='custom> '; PROMPT_COMMAND='echo custom'"); err != nil {
+			return fmt.Errorf("changing the prompt failed: %w", err)
+		}
+		return expectOutput(bs, "echo hello", "hello")
+	})
+}
+
+func checkIncomplete(opts []bash.BashSessionOption) error {
+	return withSession(opts, func(bs *bash.BashSession) error {
+		if _, err := bs.Execute(`echo "unterminated`); err == nil || !strings.Contains(err.Error(), "incomplete") {
+			return fmt.Errorf("unterminated quote returned error %v, want an incomplete command error", err)
+		}
+		return expectOutput(bs, "echo recovered", "recovered")
+	})
+}
+
+func checkTimeout(opts []bash.BashSessionOption) error {
+	return withSession(opts, func(bs *bash.BashSession) error {
+		if _, err := bs.ExecuteWithTimeout("sleep 5", time.Millisecond*300); err == nil || !strings.Contains(err.Error(), "timed out") {
+			return fmt.Errorf("sleep returned error %v, want a timeout error", err)
+		}
+		return expectOutput(bs, "echo recovered", "recovered")
+	})
+}
+
+func checkTruncation(opts []bash.BashSessionOption) error {
+	opts = append(opts[:len(opts):len(opts)], bash.WithOutputLimit(1000))
+	return withSession(opts, func(bs *bash.BashSession) error {
+		got, err := bs.Execute("seq 1 2000")
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(got, "bytes truncated") || !strings.HasPrefix(got, "1\n2\n") || !strings.HasSuffix(got, "2000") {
+			return fmt.Errorf("output of %v bytes was not truncated to its head and tail", len(got))
+		}
+		return nil
+	})
+}
+
+func checkRestart(opts []bash.BashSessionOption) error {
+	tool := bash.NewBashTool(opts...)
+	defer tool.Close()
+
+	if _, err := tool.Invoke(map[string]any{"command": "export GA_CHECK=1"}); err != nil {
+		return err
+	}
+	got, err := tool.Invoke(map[string]any{"command": `echo "${GA_CHECK:-unset}"`, "restart": true})
+	if err != nil {
+		return err
+	}
+	if got != "unset" {
+		return fmt.Errorf("restarted session kept its environment, got %q", got)
+	}
+
+	if _, err := tool.Invoke(map[string]any{"command": "exit"}); err == nil {
+		return fmt.Errorf("exiting the shell did not return an error")
+	}
+	got, err = tool.Invoke(map[string]any{"command": "echo hello"})
+	if err != nil {
+		return fmt.Errorf("session was not restarted after the shell exited: %w", err)
+	}
+	if got != "hello" {
+		return fmt.Errorf("restarted session returned %q, want %q", got, "hello")
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package bash
+
+import (
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Environment variables never passed to the shell unless explicitly allowed
+var DEFAULT_ENV_DENY_LIST = []string{"GA_ANTHROPIC_API_KEY"}
+
+const (
+	DEFAULT_ROWS uint16 = 50
+	DEFAULT_COLS uint16 = 200
+)
+
+// WithWorkingDir sets the directory the shell starts in
+func WithWorkingDir(dir string) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.workingDir = dir
+	}
+}
+
+// WithEnv replaces the inherited environment with env, given as "KEY=value" pairs
+func WithEnv(env []string) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.env = env
+	}
+}
+
+// WithEnvAllow restricts the environment to the named variables. Names may be
+// glob patterns such as "GO*". Exact names, but not patterns, override the
+// default deny list.
+func WithEnvAllow(names ...string) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.envAllow = append(bs.envAllow, names...)
+	}
+}
+
+// WithEnvDeny removes the named variables from the environment. Names may be
+// glob patterns such as "AWS_*".
+func WithEnvDeny(names ...string) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.envDeny = append(bs.envDeny, names...)
+	}
+}
+
+// WithShell runs an alternative shell binary such as zsh or sh
+func WithShell(shell string) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.shell = shell
+	}
+}
+
+// WithInitScript sources the script at path once the shell has started
+func WithInitScript(path string) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.initScript = path
+	}
+}
+
+func WithWindowSize(rows uint16, cols uint16) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.rows = rows
+		bs.cols = cols
+	}
+}
+
+// shellArgs returns the arguments that start shell interactively without
+// reading any startup files or enabling line editing
+func shellArgs(shell string) []string {
+	switch filepath.Base(shell) {
+	case "bash":
+		return []string{"--norc", "--noprofile", "--noediting", "-i"}
+	case "zsh":
+		return []string{"-f", "+Z", "-i"}
+	default:
+		return []string{"-i"}
+	}
+}
+
+func (bs *BashSession) environment(base []string) []string {
+	if bs.env != nil {
+		base = bs.env
+	}
+
+	env := []string{}
+	for _, kv := range base {
+		name, _, _ := strings.Cut(kv, "=")
+
+		allowed := matchesAny(name, bs.envAllow)
+		if len(bs.envAllow) > 0 && !allowed {
+			continue
+		}
+		if matchesAny(name, bs.envDeny) || (!slices.Contains(bs.envAllow, name) && matchesAny(name, DEFAULT_ENV_DENY_LIST)) {
+			continue
+		}
+		env = append(env, kv)
+	}
+
+	if !bs.rawOutput {
+		env = append(env, "TERM=dumb", "NO_COLOR=1")
+	}
+	return env
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package bash
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEnvironment(t *testing.T) {
+	base := []string{"HOME=/home/agent", "GOPATH=/go", "GOROOT=/usr/local/go", "AWS_REGION=eu-west-1", "AWS_SECRET_ACCESS_KEY=secret", "GA_ANTHROPIC_API_KEY=key"}
+
+	tests := []struct {
+		name string
+		opts []BashSessionOption
+		want []string
+	}{
+		{
+			name: "default deny list",
+			want: []string{"HOME=/home/agent", "GOPATH=/go", "GOROOT=/usr/local/go", "AWS_REGION=eu-west-1", "AWS_SECRET_ACCESS_KEY=secret", "TERM=dumb", "NO_COLOR=1"},
+		},
+		{
+			name: "deny glob",
+			opts: []BashSessionOption{WithEnvDeny("AWS_*")},
+			want: []string{"HOME=/home/agent", "GOPATH=/go", "GOROOT=/usr/local/go", "TERM=dumb", "NO_COLOR=1"},
+		},
+		{
+			name: "allow glob",
+			opts: []BashSessionOption{WithEnvAllow("GO*")},
+			want: []string{"GOPATH=/go", "GOROOT=/usr/local/go", "TERM=dumb", "NO_COLOR=1"},
+		},
+		{
+			name: "deny wins over allow",
+			opts: []BashSessionOption{WithEnvAllow("GO*", "HOME"), WithEnvDeny("GOROOT")},
+			want: []string{"HOME=/home/agent", "GOPATH=/go", "TERM=dumb", "NO_COLOR=1"},
+		},
+		{
+			name: "allow pattern keeps the default deny list",
+			opts: []BashSessionOption{WithEnvAllow("*")},
+			want: []string{"HOME=/home/agent", "GOPATH=/go", "GOROOT=/usr/local/go", "AWS_REGION=eu-west-1", "AWS_SECRET_ACCESS_KEY=secret", "TERM=dumb", "NO_COLOR=1"},
+		},
+		{
+			name: "exact allow overrides the default deny list",
+			opts: []BashSessionOption{WithEnvAllow("GA_ANTHROPIC_API_KEY")},
+			want: []string{"GA_ANTHROPIC_API_KEY=key", "TERM=dumb", "NO_COLOR=1"},
+		},
+		{
+			name: "malformed pattern matches nothing",
+			opts: []BashSessionOption{WithEnvDeny("[")},
+			want: []string{"HOME=/home/agent", "GOPATH=/go", "GOROOT=/usr/local/go", "AWS_REGION=eu-west-1", "AWS_SECRET_ACCESS_KEY=secret", "TERM=dumb", "NO_COLOR=1"},
+		},
+		{
+			name: "explicit environment",
+			opts: []BashSessionOption{WithEnv([]string{"PATH=/bin", "GA_ANTHROPIC_API_KEY=key"})},
+			want: []string{"PATH=/bin", "TERM=dumb", "NO_COLOR=1"},
+		},
+		{
+			name: "raw output",
+			opts: []BashSessionOption{WithEnv([]string{"PATH=/bin"}), WithRawOutput()},
+			want: []string{"PATH=/bin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bs := &BashSession{}
+			for _, opt := range tt.opts {
+				opt(bs)
+			}
+			if got := bs.environment(base); !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
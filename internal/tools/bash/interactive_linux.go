@@ -0,0 +1,123 @@
+package bash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// foregroundPgrp returns the process group that currently owns the terminal
+func (bs *BashSession) foregroundPgrp() (int, bool) {
+	f, ok := bs.tty.(interface {
+		SyscallConn() (syscall.RawConn, error)
+	})
+	if !ok {
+		return 0, false
+	}
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+
+	var pgrp int32
+	var errno syscall.Errno
+	conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp)))
+	})
+	if errno != 0 {
+		return 0, false
+	}
+	return int(pgrp), true
+}
+
+// waitingForInput reports whether a program other than the shell holds the
+// terminal and is blocked reading from it, returning the program's name
+func (bs *BashSession) waitingForInput() (string, bool) {
+	pgrp, ok := bs.foregroundPgrp()
+	if !ok || pgrp == bs.pid {
+		return "", false
+	}
+
+	for _, pid := range processGroupMembers(pgrp) {
+		if blockedOnTerminal(pid) {
+			comm, _ := os.ReadFile(fmt.Sprintf("/proc/%v/comm", pid))
+			return strings.TrimSpace(string(comm)), true
+		}
+	}
+	return "", false
+}
+
+func (bs *BashSession) killForeground() {
+	pgrp, ok := bs.foregroundPgrp()
+	if !ok || pgrp == bs.pid {
+		return
+	}
+	syscall.Kill(-pgrp, syscall.SIGKILL)
+}
+
+func processGroupMembers(pgrp int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	members := []int{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%v/stat", pid))
+		if err != nil {
+			continue
+		}
+		// Fields after the parenthesised command name: state ppid pgrp ...
+		i := strings.LastIndexByte(string(stat), ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[i+1:]))
+		if len(fields) > 2 && fields[2] == strconv.Itoa(pgrp) {
+			members = append(members, pid)
+		}
+	}
+	return members
+}
+
+// blockedOnTerminal reports whether pid is sleeping in read(2) on a terminal,
+// or in pselect/ppoll with a terminal on stdin, as REPLs and pagers do
+func blockedOnTerminal(pid int) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%v/syscall", pid))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return false
+	}
+	nr, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return false
+	}
+
+	switch nr {
+	case syscall.SYS_READ:
+		fd, err := strconv.ParseInt(strings.TrimPrefix(fields[1], "0x"), 16, 64)
+		return err == nil && isTerminal(pid, int(fd))
+	case syscall.SYS_PSELECT6, syscall.SYS_PPOLL:
+		return isTerminal(pid, 0)
+	}
+	return false
+}
+
+func isTerminal(pid int, fd int) bool {
+	target, err := os.Readlink(fmt.Sprintf("/proc/%v/fd/%v", pid, fd))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(target, "/dev/pts/") || filepath.Base(target) == "tty"
+}
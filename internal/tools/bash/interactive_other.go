@@ -0,0 +1,9 @@
+//go:build !linux
+
+package bash
+
+func (bs *BashSession) waitingForInput() (string, bool) {
+	return "", false
+}
+
+func (bs *BashSession) killForeground() {}
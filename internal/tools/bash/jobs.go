@@ -0,0 +1,408 @@
+package bash
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	toolschema "github.com/frozenkro/go-agent/models/anthropic/tool_schema"
+	"github.com/mitchellh/mapstructure"
+)
+
+// Job is a command running in the background in its own shell session. Its
+// output is captured continuously so it can be read incrementally.
+type Job struct {
+	Name    string
+	Command string
+
+	bs     *BashSession
+	mu     sync.Mutex
+	output strings.Builder
+	// readUpTo is the offset in output up to which output has been polled
+	readUpTo int
+	// dropped counts unpolled bytes discarded to keep output under JOB_OUTPUT_LIMIT
+	dropped int
+	// received counts every byte captured, including discarded ones
+	received int
+	running  bool
+	exitCode int
+	err      error
+	endOnce  sync.Once
+	endErr   error
+}
+
+// JOB_OUTPUT_LIMIT caps the output buffered for a job. Older output is
+// discarded once it is exceeded, so a job that is never polled cannot use
+// unbounded memory. After input is sent to a job its output is reported once
+// it has been quiet for JOB_OUTPUT_SETTLE, or after at most INTERACTIVE_IDLE.
+const (
+	JOB_OUTPUT_LIMIT  int           = 1 << 20
+	JOB_OUTPUT_SETTLE time.Duration = time.Millisecond * 200
+)
+
+// BashSessionTool runs commands in named persistent shell sessions
+type BashSessionTool struct {
+	bt *BashTool
+}
+
+// BashJobTool starts and manages background jobs
+type BashJobTool struct {
+	bt *BashTool
+}
+
+func (t *BashTool) SessionTool() BashSessionTool {
+	return BashSessionTool{bt: t}
+}
+
+func (t *BashTool) JobTool() BashJobTool {
+	return BashJobTool{bt: t}
+}
+
+func (t BashSessionTool) Invoke(params any) (string, error) {
+	var p toolschema.BashSessionToolInput
+	err := mapstructure.Decode(params, &p)
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse invoke params for BashSessionTool: '%v'", params)
+	}
+
+	name := p.Session
+	if name == "" {
+		name = DEFAULT_SESSION
+	}
+	return t.bt.executeInSession(name, p.Command, p.Restart)
+}
+
+func (t BashJobTool) Invoke(params any) (string, error) {
+	var p toolschema.BashJobToolInput
+	err := mapstructure.Decode(params, &p)
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse invoke params for BashJobTool: '%v'", params)
+	}
+
+	if p.Action == toolschema.JOB_START {
+		job, err := t.bt.StartJob(p.Job, p.Command)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Started job '%v'", job.Name), nil
+	}
+	if p.Action == toolschema.JOB_LIST {
+		return t.bt.listJobs(), nil
+	}
+
+	job, err := t.bt.job(p.Job)
+	if err != nil {
+		return "", err
+	}
+
+	switch p.Action {
+	case toolschema.JOB_POLL:
+		return job.Poll(), nil
+	case toolschema.JOB_SEND:
+		from := job.receivedBytes()
+		if err := job.Send(p.Input); err != nil {
+			return "", err
+		}
+		job.awaitOutput(from)
+		return job.Poll(), nil
+	case toolschema.JOB_KILL:
+		err := job.Kill()
+		t.bt.mu.Lock()
+		delete(t.bt.jobs, job.Name)
+		t.bt.mu.Unlock()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Killed job '%v'\n%v", job.Name, job.Poll()), nil
+	default:
+		return "", fmt.Errorf("Unknown job action '%v'", p.Action)
+	}
+}
+
+// StartJob runs command in a new shell session without waiting for it to finish
+func (t *BashTool) StartJob(name string, command string) (*Job, error) {
+	if command == "" {
+		return nil, fmt.Errorf("A command is required to start a job")
+	}
+
+	t.mu.Lock()
+	if name == "" {
+		t.jobCount++
+		name = fmt.Sprintf("job-%v", t.jobCount)
+	}
+	if existing, ok := t.jobs[name]; ok && existing.Running() {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("Job '%v' is already running", name)
+	}
+	// Reserve the name while the shell starts, without holding the lock
+	job := &Job{
+		Name:     name,
+		Command:  command,
+		running:  true,
+		exitCode: EXIT_UNKNOWN,
+	}
+	previous, hadPrevious := t.jobs[name]
+	t.jobs[name] = job
+	t.mu.Unlock()
+
+	unreserve := func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.jobs[name] != job {
+			return
+		}
+		if hadPrevious {
+			t.jobs[name] = previous
+		} else {
+			delete(t.jobs, name)
+		}
+	}
+
+	bs, err := NewBashSession(t.opts...)
+	if err != nil {
+		unreserve()
+		return nil, fmt.Errorf("Error initializing Bash Session for job '%v': %w", name, err)
+	}
+	if err := bs.armCPULimit(); err != nil {
+		bs.Deinit()
+		unreserve()
+		return nil, err
+	}
+
+	job.mu.Lock()
+	job.bs = bs
+	job.mu.Unlock()
+	bs.sendCommand(wrapCommand(command))
+	go job.capture()
+	return job, nil
+}
+
+func (t *BashTool) job(name string) (*Job, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job, ok := t.jobs[name]
+	if !ok {
+		return nil, fmt.Errorf("No job found with name '%v'", name)
+	}
+	return job, nil
+}
+
+func (t *BashTool) listJobs() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.jobs) == 0 {
+		return "No jobs"
+	}
+
+	names := make([]string, 0, len(t.jobs))
+	for name := range t.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		job := t.jobs[name]
+		lines[i] = fmt.Sprintf("%v [%v]: %v", name, job.status(), job.Command)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (j *Job) capture() {
+	buffer := make([]byte, BUFFER_SIZE)
+	scanner := promptScanner{bs: j.bs}
+	j.bs.tty.SetReadDeadline(time.Time{})
+
+	for {
+		n, err := j.bs.tty.Read(buffer)
+
+		j.mu.Lock()
+		if n > 0 {
+			j.output.Write(buffer[:n])
+			j.received += n
+			j.limitOutput(&scanner)
+		}
+		if done, exitCode := scanner.scan(j.output.String()); done {
+			j.running = false
+			j.exitCode = exitCode
+		}
+		if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+			j.running = false
+			j.err = err
+		}
+		running := j.running
+		j.mu.Unlock()
+
+		if !running {
+			// The shell is left at its prompt once the command finishes
+			j.endShell()
+			return
+		}
+	}
+}
+
+// endShell ends the job's shell once, keeping its saved outputs for Poll
+func (j *Job) endShell() error {
+	j.endOnce.Do(func() {
+		j.endErr = j.bs.endShell()
+	})
+	return j.endErr
+}
+
+func (j *Job) receivedBytes() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.received
+}
+
+// awaitOutput waits until the job has produced output beyond from and gone
+// quiet, has finished, or INTERACTIVE_IDLE has passed
+func (j *Job) awaitOutput(from int) {
+	start := time.Now()
+	lastOutput := start
+	last := from
+
+	for time.Since(start) < INTERACTIVE_IDLE {
+		time.Sleep(BUFFER_POLL_RATE)
+		j.mu.Lock()
+		received, running := j.received, j.running
+		j.mu.Unlock()
+
+		if !running {
+			return
+		}
+		if received != last {
+			last = received
+			lastOutput = time.Now()
+		} else if last != from && time.Since(lastOutput) >= JOB_OUTPUT_SETTLE {
+			return
+		}
+	}
+}
+
+func (j *Job) Running() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.running
+}
+
+func (j *Job) status() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.statusLocked()
+}
+
+func (j *Job) statusLocked() string {
+	if j.running {
+		return "running"
+	}
+	if j.exitCode != EXIT_UNKNOWN {
+		return fmt.Sprintf("finished, exit status %v", j.exitCode)
+	}
+	return "finished"
+}
+
+// limitOutput discards the oldest output once JOB_OUTPUT_LIMIT is exceeded,
+// keeping the newest half, and moves the offsets into output to match
+func (j *Job) limitOutput(scanner *promptScanner) {
+	if j.output.Len() <= JOB_OUTPUT_LIMIT {
+		return
+	}
+
+	output := j.output.String()
+	drop := len(output) - JOB_OUTPUT_LIMIT/2
+	for drop < len(output) && !utf8.RuneStart(output[drop]) {
+		drop++
+	}
+	j.dropped += max(drop-j.readUpTo, 0)
+	j.readUpTo = max(j.readUpTo-drop, 0)
+	scanner.doneFrom = max(scanner.doneFrom-drop, 0)
+	scanner.contFrom = max(scanner.contFrom-drop, 0)
+
+	j.output.Reset()
+	j.output.WriteString(output[drop:])
+}
+
+// Poll returns the output produced since the previous poll along with the
+// job's status
+func (j *Job) Poll() string {
+	j.mu.Lock()
+	if j.bs == nil {
+		j.mu.Unlock()
+		return fmt.Sprintf("[job '%v' starting, no new output]", j.Name)
+	}
+	output := j.output.String()
+	end := len(output)
+	if j.running {
+		end = j.bs.completeOutputEnd(output, j.readUpTo)
+	}
+	newOutput := strings.Trim(j.bs.cleanOutput(output[j.readUpTo:end], j.Command), "\n")
+	if j.dropped > 0 {
+		newOutput = strings.TrimLeft(fmt.Sprintf("[... %v bytes of earlier output discarded ...]\n%v", j.dropped, newOutput), "\n")
+		j.dropped = 0
+	}
+	j.readUpTo = end
+	status := j.statusLocked()
+	j.mu.Unlock()
+
+	if newOutput == "" {
+		return fmt.Sprintf("[job '%v' %v, no new output]", j.Name, status)
+	}
+	return fmt.Sprintf("%v\n[job '%v' %v]", j.bs.truncateOutput(newOutput), j.Name, status)
+}
+
+// completeOutputEnd returns the end of the output that can be polled, leaving
+// out a marker that has only partly arrived
+func (bs *BashSession) completeOutputEnd(output string, from int) int {
+	i := strings.LastIndex(output[from:], MARKER_PREFIX[:1])
+	if i < 0 {
+		return len(output)
+	}
+	tail := output[from+i:]
+	for _, marker := range []string{bs.doneMarker(), bs.contMarker()} {
+		if len(tail) < len(marker) && strings.HasPrefix(marker, tail) {
+			return from + i
+		}
+	}
+	return len(output)
+}
+
+// Send writes input followed by a newline to the job's terminal
+func (j *Job) Send(input string) error {
+	bs := j.session()
+	if bs == nil {
+		return fmt.Errorf("Job '%v' is still starting", j.Name)
+	}
+	if !j.Running() {
+		return fmt.Errorf("Job '%v' has finished", j.Name)
+	}
+	bs.sendCommand(input)
+	return nil
+}
+
+// Kill interrupts the job and ends its shell session
+func (j *Job) Kill() error {
+	bs := j.session()
+	if bs == nil {
+		return nil
+	}
+	if j.Running() {
+		// Ctrl-C interrupts the foreground process
+		bs.tty.Write([]byte{3})
+	}
+	return errors.Join(j.endShell(), bs.removeSpillFiles())
+}
+
+// session returns the job's shell session, or nil while it is starting
+func (j *Job) session() *BashSession {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.bs
+}
@@ -0,0 +1,101 @@
+package bash_test
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frozenkro/go-agent/internal/tools/bash"
+	"github.com/frozenkro/go-agent/internal/tools/bash/bashtest"
+	toolschema "github.com/frozenkro/go-agent/models/anthropic/tool_schema"
+)
+
+func requireBash(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+}
+
+func TestJobSendWaitsForOutput(t *testing.T) {
+	requireBash(t)
+	tool := bash.NewBashTool()
+	defer tool.Close()
+	jobs := tool.JobTool()
+
+	if _, err := jobs.Invoke(map[string]any{"action": toolschema.JOB_START, "job": "reader", "command": `read line; sleep 0.5; echo "got $line"; sleep 10`}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := jobs.Invoke(map[string]any{"action": toolschema.JOB_SEND, "job": "reader", "input": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "got hi\n") {
+		t.Errorf("Expected the reply to the input, got %q", got)
+	}
+}
+
+// closeTTY records when the session closes its terminal
+type closeTTY struct {
+	*bashtest.FakeTerminal
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (c *closeTTY) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return c.FakeTerminal.Close()
+}
+
+func TestFinishedJobEndsShell(t *testing.T) {
+	tty := &closeTTY{
+		FakeTerminal: bashtest.NewFakeTerminal().On("make", bashtest.Response{Output: "built\n", Delay: time.Millisecond * 50}),
+		closed:       make(chan struct{}),
+	}
+	tool := bash.NewBashTool(bash.WithTTY(func() (bash.TTY, error) { return tty, nil }))
+	defer tool.Close()
+
+	job, err := tool.StartJob("build", "make")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tty.closed:
+	case <-time.After(time.Second * 2):
+		t.Fatal("Expected the shell to end when the job finished")
+	}
+	if got := job.Poll(); got != "built\n[job 'build' finished, exit status 0]" {
+		t.Errorf("Expected the output to be kept after the shell ended, got %q", got)
+	}
+}
+
+func TestSessionConcurrentCommands(t *testing.T) {
+	requireBash(t)
+	tool := bash.NewBashTool()
+	defer tool.Close()
+	sessions := tool.SessionTool()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			want := fmt.Sprintf("command %v", i)
+			got, err := sessions.Invoke(map[string]any{"session": "shared", "command": fmt.Sprintf("sleep 0.0%v; echo '%v'", i, want)})
+			if err != nil {
+				errs <- err
+			} else if got != want {
+				errs <- fmt.Errorf("Expected %q, got %q", want, got)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
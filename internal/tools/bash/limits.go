@@ -0,0 +1,57 @@
+package bash
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ResourceLimits caps the resources available to a BashSession and every
+// process it spawns. Zero values leave the corresponding limit unset.
+//
+// Memory and Processes are enforced through a cgroup v2 subtree when one can
+// be created, and through RLIMIT_AS and RLIMIT_NPROC otherwise. Note that
+// RLIMIT_NPROC counts every process owned by the user, not only the session's.
+// CPUTime applies to each process started by a command, and to the shell's own
+// time during the command. A process past it is sent SIGXCPU, which ends it
+// unless the process handles the signal.
+type ResourceLimits struct {
+	CPUTime   time.Duration
+	Memory    uint64
+	Processes uint64
+	FileSize  uint64
+	OpenFiles uint64
+}
+
+func WithResourceLimits(limits ResourceLimits) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.limits = &limits
+	}
+}
+
+const (
+	// EXIT_SIGNAL_BASE is added to the signal number in the exit status bash
+	// reports for a command killed by a signal
+	EXIT_SIGNAL_BASE int = 128
+	// OOM_VIOLATION is reported when the cgroup killed a process for memory
+	OOM_VIOLATION string = "memory (process killed)"
+)
+
+// limitViolations returns a note for the model describing any resource limit
+// the last command hit, judged by the signal that ended it and the session's
+// cgroup events, or an empty string if none were detected.
+func (bs *BashSession) limitViolations() string {
+	if bs.limits == nil {
+		return ""
+	}
+
+	violations := bs.cgroupViolations()
+	if v := bs.signalViolation(bs.lastExitCode - EXIT_SIGNAL_BASE); v != "" {
+		violations = append(violations, v)
+	}
+
+	if len(violations) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n[Resource limit exceeded: %v]", strings.Join(violations, ", "))
+}
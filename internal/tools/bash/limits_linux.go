@@ -0,0 +1,255 @@
+package bash
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	CGROUP_ROOT string = "/sys/fs/cgroup"
+	// Times removing a cgroup is tried while its processes exit
+	CGROUP_REMOVE_ATTEMPTS int = 50
+	// CLOCK_TICKS is the unit of CPU times in /proc, USER_HZ, which is 100 on
+	// every architecture Linux supports
+	CLOCK_TICKS int64 = 100
+	// How long to wait for the shell's exit status after its terminal closes
+	SHELL_EXIT_WAIT time.Duration = time.Second
+)
+
+// applyLimits sets the configured limits on the freshly started shell. Limits
+// are inherited by every process the shell spawns.
+func (bs *BashSession) applyLimits(pid int) error {
+	if bs.limits == nil {
+		return nil
+	}
+	l := bs.limits
+
+	cgroupErr := bs.createCgroup(pid)
+
+	rlimits := map[int]uint64{}
+	if l.FileSize > 0 {
+		rlimits[unix.RLIMIT_FSIZE] = l.FileSize
+	}
+	if l.OpenFiles > 0 {
+		rlimits[unix.RLIMIT_NOFILE] = l.OpenFiles
+	}
+	if cgroupErr != nil {
+		if l.Memory > 0 {
+			rlimits[unix.RLIMIT_AS] = l.Memory
+		}
+		if l.Processes > 0 {
+			rlimits[unix.RLIMIT_NPROC] = l.Processes
+		}
+	}
+
+	for resource, value := range rlimits {
+		if err := prlimit(pid, resource, value, value); err != nil {
+			return fmt.Errorf("Unable to apply resource limit %v: %w", resource, err)
+		}
+	}
+	return nil
+}
+
+// armCPULimit gives the next command CPUTime of CPU time. RLIMIT_CPU counts
+// the CPU time a process has used since it started, so the shell's soft limit
+// is moved past the time it has used already, and the processes it starts
+// inherit the limit with none used. Only the soft limit is set, as the hard
+// limit could not be raised again for the next command.
+func (bs *BashSession) armCPULimit() error {
+	if bs.limits == nil || bs.limits.CPUTime <= 0 || bs.pid == 0 {
+		return nil
+	}
+
+	used, err := processCPUTime(bs.pid)
+	if err != nil {
+		return err
+	}
+	limit := uint64(math.Ceil((used + bs.limits.CPUTime).Seconds()))
+	if err := prlimit(bs.pid, unix.RLIMIT_CPU, limit, unix.RLIM_INFINITY); err != nil {
+		return fmt.Errorf("Unable to apply CPU time limit: %w", err)
+	}
+	return nil
+}
+
+// processCPUTime returns the user and system time used by the process pid
+func processCPUTime(pid int) (time.Duration, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%v/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	// Fields after the command name, which may contain spaces, start at the
+	// process state. utime and stime are fields 14 and 15 of the file.
+	_, rest, ok := strings.Cut(string(data), ") ")
+	fields := strings.Fields(rest)
+	if !ok || len(fields) < 13 {
+		return 0, fmt.Errorf("Unable to parse /proc/%v/stat", pid)
+	}
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(utime+stime) * time.Second / time.Duration(CLOCK_TICKS), nil
+}
+
+func prlimit(pid int, resource int, soft uint64, hard uint64) error {
+	return unix.Prlimit(pid, resource, &unix.Rlimit{Cur: soft, Max: hard}, nil)
+}
+
+// createCgroup places the shell in a new cgroup v2 subtree below the current
+// process's cgroup. It fails when cgroup v2 is not mounted or the subtree has
+// not been delegated to this user.
+func (bs *BashSession) createCgroup(pid int) error {
+	if bs.limits.Memory == 0 && bs.limits.Processes == 0 {
+		return fmt.Errorf("No cgroup limits configured")
+	}
+	if _, err := os.Stat(filepath.Join(CGROUP_ROOT, "cgroup.controllers")); err != nil {
+		return fmt.Errorf("cgroup v2 is not available: %w", err)
+	}
+
+	self, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return err
+	}
+	parent := ""
+	for _, line := range strings.Split(string(self), "\n") {
+		if p, ok := strings.CutPrefix(line, "0::"); ok {
+			parent = p
+		}
+	}
+
+	dir := filepath.Join(CGROUP_ROOT, parent, fmt.Sprintf("go-agent-%v", uuid.New()))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+
+	settings := map[string]uint64{
+		"memory.max": bs.limits.Memory,
+		"pids.max":   bs.limits.Processes,
+	}
+	for file, value := range settings {
+		if value == 0 {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(strconv.FormatUint(value, 10)), 0644); err != nil {
+			os.Remove(dir)
+			return err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		os.Remove(dir)
+		return err
+	}
+
+	bs.cgroup = dir
+	bs.cgroupEvents = bs.readCgroupEvents()
+	return nil
+}
+
+func (bs *BashSession) readCgroupEvents() map[string]int {
+	events := map[string]int{}
+	for file, key := range map[string]string{"memory.events": "oom_kill", "pids.events": "max"} {
+		data, err := os.ReadFile(filepath.Join(bs.cgroup, file))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == key {
+				events[file], _ = strconv.Atoi(fields[1])
+			}
+		}
+	}
+	return events
+}
+
+// cgroupViolations reports limits that were hit since the last call
+func (bs *BashSession) cgroupViolations() []string {
+	if bs.cgroup == "" {
+		return nil
+	}
+
+	violations := []string{}
+	events := bs.readCgroupEvents()
+	if events["memory.events"] > bs.cgroupEvents["memory.events"] {
+		violations = append(violations, OOM_VIOLATION)
+	}
+	if events["pids.events"] > bs.cgroupEvents["pids.events"] {
+		violations = append(violations, "processes")
+	}
+	bs.cgroupEvents = events
+
+	return violations
+}
+
+// signalViolation returns the limit that sent signal, if any. A SIGKILL is
+// not attributed to a limit, as it may come from a user or an interrupt, and
+// an out of memory kill is reported by the cgroup.
+func (bs *BashSession) signalViolation(signal int) string {
+	switch unix.Signal(signal) {
+	case unix.SIGXCPU:
+		return "cpu time"
+	case unix.SIGXFSZ:
+		return "file size"
+	}
+	return ""
+}
+
+// shellViolation returns a note describing the limit that ended the shell
+// itself, e.g. a builtin loop using up the CPU time limit
+func (bs *BashSession) shellViolation() string {
+	if bs.limits == nil || bs.shellExited == nil {
+		return ""
+	}
+
+	select {
+	case <-bs.shellExited:
+	case <-time.After(SHELL_EXIT_WAIT):
+		return ""
+	}
+	status, ok := bs.shellState.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+	if v := bs.signalViolation(int(status.Signal())); v != "" {
+		return fmt.Sprintf("\n[Resource limit exceeded: %v]", v)
+	}
+	return ""
+}
+
+// removeCgroup kills any process left in the session's cgroup and removes it
+func (bs *BashSession) removeCgroup() error {
+	if bs.cgroup == "" {
+		return nil
+	}
+
+	// cgroup.kill needs Linux 5.14, before that the processes are left to
+	// exit on SIGHUP from the closed terminal
+	os.WriteFile(filepath.Join(bs.cgroup, "cgroup.kill"), []byte("1"), 0644)
+
+	var err error
+	for range CGROUP_REMOVE_ATTEMPTS {
+		err = os.Remove(bs.cgroup)
+		if err == nil || errors.Is(err, fs.ErrNotExist) {
+			bs.cgroup = ""
+			return nil
+		}
+		time.Sleep(BUFFER_POLL_RATE)
+	}
+	return fmt.Errorf("Unable to remove cgroup '%v': %w", bs.cgroup, err)
+}
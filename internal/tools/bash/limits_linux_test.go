@@ -0,0 +1,67 @@
+package bash_test
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frozenkro/go-agent/internal/tools/bash"
+)
+
+func TestResourceLimitViolations(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	bs, err := bash.NewBashSession(bash.WithResourceLimits(bash.ResourceLimits{CPUTime: time.Second, FileSize: 1024}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bs.Deinit()
+	dir := t.TempDir()
+
+	// Every command gets the full CPU time, so the limit is hit twice
+	tests := []struct {
+		name      string
+		command   string
+		violation string
+	}{
+		{"cpu time", "sh -c 'while :; do :; done'", "cpu time"},
+		{"cpu time again", "sh -c 'while :; do :; done'", "cpu time"},
+		{"file size", "head -c 4096 /dev/zero > " + dir + "/f", "file size"},
+		{"kill -9", "sh -c 'kill -9 $$'", ""},
+		{"limit text in output", "echo 'CPU time limit exceeded'", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := bs.Execute(tt.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, note, found := strings.Cut(output, "[Resource limit exceeded: ")
+			if tt.violation == "" && found {
+				t.Errorf("Expected no violation, got %q", output)
+			}
+			if tt.violation != "" && !strings.HasPrefix(note, tt.violation+"]") {
+				t.Errorf("Expected a %v violation, got %q", tt.violation, output)
+			}
+		})
+	}
+}
+
+func TestResourceLimitEndsShell(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	bs, err := bash.NewBashSession(bash.WithResourceLimits(bash.ResourceLimits{CPUTime: time.Second}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bs.Deinit()
+
+	// A builtin loop uses the shell's own CPU time
+	_, err = bs.Execute("while :; do :; done")
+	if err == nil || !strings.Contains(err.Error(), "[Resource limit exceeded: cpu time]") {
+		t.Fatalf("Expected the shell to end on the cpu time limit, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+
+package bash
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func (bs *BashSession) applyLimits(pid int) error {
+	if bs.limits == nil {
+		return nil
+	}
+	return fmt.Errorf("Resource limits are not supported on %v", runtime.GOOS)
+}
+
+func (bs *BashSession) cgroupViolations() []string {
+	return nil
+}
+
+func (bs *BashSession) armCPULimit() error {
+	return nil
+}
+
+func (bs *BashSession) signalViolation(signal int) string {
+	return ""
+}
+
+func (bs *BashSession) shellViolation() string {
+	return ""
+}
+
+func (bs *BashSession) removeCgroup() error {
+	return nil
+}
@@ -0,0 +1,102 @@
+package bash
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+const DEFAULT_OUTPUT_LIMIT int = 30000
+
+// WithOutputLimit caps the size of the output returned from a command. Output
+// over the limit keeps its head and tail, and is saved in full to a file whose
+// path is included in the returned output. The file is removed when the
+// session ends. A limit of 0 disables truncation.
+func WithOutputLimit(limit int) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.outputLimit = limit
+	}
+}
+
+// WithSpillDir sets the directory that full outputs over the limit are saved
+// to. Defaults to the sandbox workspace for sandboxed sessions, otherwise the
+// system temp directory.
+func WithSpillDir(dir string) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.spillDir = dir
+	}
+}
+
+func (bs *BashSession) truncateOutput(output string) string {
+	if bs.outputLimit <= 0 || len(output) <= bs.outputLimit {
+		return output
+	}
+
+	// Cut on rune boundaries so multi-byte characters are not split
+	headEnd := bs.outputLimit / 2
+	for headEnd > 0 && !utf8.RuneStart(output[headEnd]) {
+		headEnd--
+	}
+	tailStart := len(output) - (bs.outputLimit - bs.outputLimit/2)
+	for tailStart < len(output) && !utf8.RuneStart(output[tailStart]) {
+		tailStart++
+	}
+	truncated := tailStart - headEnd
+
+	note := fmt.Sprintf("%v bytes truncated", truncated)
+	if path, err := bs.spillOutput(output); err == nil {
+		note = fmt.Sprintf("%v; full output (%v bytes) saved to %v", note, len(output), path)
+	} else {
+		note = fmt.Sprintf("%v; full output could not be saved: %v", note, err)
+	}
+
+	return fmt.Sprintf("%v\n\n[... %v ...]\n\n%v", output[:headEnd], note, output[tailStart:])
+}
+
+func (bs *BashSession) spillOutput(output string) (string, error) {
+	dir := bs.spillDir
+	if dir == "" && bs.sandbox != nil {
+		dir = bs.sandbox.Workspace
+		if dir == "" {
+			dir = "."
+		}
+	}
+	if dir != "" {
+		var err error
+		if dir, err = filepath.Abs(dir); err != nil {
+			return "", err
+		}
+	}
+
+	f, err := os.CreateTemp(dir, "go-agent-output-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	bs.spillMu.Lock()
+	bs.spillFiles = append(bs.spillFiles, f.Name())
+	bs.spillMu.Unlock()
+
+	if _, err := f.WriteString(output); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// removeSpillFiles deletes the files full outputs were saved to
+func (bs *BashSession) removeSpillFiles() error {
+	bs.spillMu.Lock()
+	defer bs.spillMu.Unlock()
+
+	errs := []error{}
+	for _, path := range bs.spillFiles {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	bs.spillFiles = nil
+	return errors.Join(errs...)
+}
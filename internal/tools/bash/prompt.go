@@ -0,0 +1,163 @@
+package bash
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The shell reports that it is ready for a new command by printing an OSC
+// escape sequence carrying the session id and the exit status of the last
+// command. A second marker is used as PS2 so continuation prompts caused by
+// incomplete input can be detected instead of waiting for a prompt forever.
+const (
+	MARKER_PREFIX    string        = "\x1b]777;go-agent;"
+	MARKER_SUFFIX    string        = "\x07"
+	INTERACTIVE_IDLE time.Duration = time.Second * 2
+	INTERRUPT_GRACE  time.Duration = time.Millisecond * 500
+	EXIT_UNKNOWN     int           = -1
+)
+
+func (bs *BashSession) doneMarker() string {
+	return fmt.Sprintf("%v%v;done", MARKER_PREFIX, bs.sessionId)
+}
+
+func (bs *BashSession) contMarker() string {
+	return fmt.Sprintf("%v%v;cont%v", MARKER_PREFIX, bs.sessionId, MARKER_SUFFIX)
+}
+
+// promptSetup returns the command that installs the markers in the running
+// shell and disables terminal echo
+func (bs *BashSession) promptSetup() string {
+	done := fmt.Sprintf(`\033]777;go-agent;%v;done;%%d\007`, bs.sessionId)
+	cont := fmt.Sprintf(`\033]777;go-agent;%v;cont\007`, bs.sessionId)
+
+	// The prompts are reset before every prompt so commands that change PS1
+	// or PS2 cannot break completion detection. In bash the hook itself is
+	// made readonly, so assigning PROMPT_COMMAND fails instead.
+	switch filepath.Base(bs.shell) {
+	case "bash":
+		return fmt.Sprintf(`stty -echo; PROMPT_COMMAND='__ga_status=$?; PS1=""; PS2=$'"'"'%v'"'"'; printf "%v" $__ga_status'; readonly PROMPT_COMMAND`, cont, done)
+	case "zsh":
+		return fmt.Sprintf(`stty -echo; unsetopt PROMPT_SP PROMPT_CR; precmd() { local s=$?; PS1=""; PS2=$'%v'; printf '%v' $s }`, cont, done)
+	default:
+		// POSIX shells have no prompt hook, so the exit status is not reported
+		return fmt.Sprintf(`stty -echo; PS1="$(printf '\033]777;go-agent;%v;done\007')"; PS2="$(printf '%v')"`, bs.sessionId, cont)
+	}
+}
+
+// wrapCommand groups multi-line commands into a single compound command so
+// the shell only prints one prompt once all of it has run
+func wrapCommand(command string) string {
+	if !strings.Contains(command, "\n") {
+		return command
+	}
+	return fmt.Sprintf("{\n%v\n}", command)
+}
+
+// promptScanner incrementally searches pty output for the session's markers
+// without rescanning output it has already seen
+type promptScanner struct {
+	bs            *BashSession
+	doneFrom      int
+	contFrom      int
+	continuations int
+}
+
+// scan reports whether output contains the done marker, and the exit status
+// it carries
+func (s *promptScanner) scan(output string) (bool, int) {
+	cont := s.bs.contMarker()
+	for {
+		i := strings.Index(output[s.contFrom:], cont)
+		if i < 0 {
+			s.contFrom = max(s.contFrom, len(output)-len(cont)+1)
+			break
+		}
+		s.continuations++
+		s.contFrom += i + len(cont)
+	}
+
+	done := s.bs.doneMarker()
+	i := strings.Index(output[s.doneFrom:], done)
+	if i < 0 {
+		s.doneFrom = max(s.doneFrom, len(output)-len(done)+1)
+		return false, EXIT_UNKNOWN
+	}
+	s.doneFrom += i
+
+	rest := output[s.doneFrom+len(done):]
+	end := strings.Index(rest, MARKER_SUFFIX)
+	if end < 0 {
+		// The rest of the marker has not arrived yet
+		return false, EXIT_UNKNOWN
+	}
+
+	exitCode, err := strconv.Atoi(strings.TrimPrefix(rest[:end], ";"))
+	if err != nil {
+		exitCode = EXIT_UNKNOWN
+	}
+	return true, exitCode
+}
+
+// cleanOutput removes the markers and command echo from raw pty output
+func (bs *BashSession) cleanOutput(accumulated string, command string) string {
+	if i := strings.Index(accumulated, bs.doneMarker()); i >= 0 {
+		accumulated = accumulated[:i]
+	}
+	accumulated = strings.ReplaceAll(accumulated, bs.contMarker(), "")
+
+	result := strings.TrimSpace(accumulated)
+	if rest, ok := strings.CutPrefix(result, command); ok && (rest == "" || rest[0] == '\r' || rest[0] == '\n') {
+		result = rest
+	}
+	if !bs.rawOutput {
+		result = normalizeTerminalOutput(result)
+	}
+	return strings.TrimLeft(result, "\r\n")
+}
+
+// interrupt stops the running command with Ctrl-C, killing the foreground
+// process group if it does not return to the prompt, and discards the output
+// up to the next prompt
+func (bs *BashSession) interrupt() {
+	bs.tty.Write([]byte{3})
+	if bs.drainToPrompt(INTERRUPT_GRACE) {
+		return
+	}
+
+	bs.killForeground()
+	bs.drainToPrompt(INTERRUPT_GRACE)
+}
+
+func (bs *BashSession) drainToPrompt(timeout time.Duration) bool {
+	buffer := make([]byte, BUFFER_SIZE)
+	var accumulated strings.Builder
+	scanner := promptScanner{bs: bs}
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		bs.tty.SetReadDeadline(time.Now().Add(BUFFER_POLL_RATE))
+		n, _ := bs.tty.Read(buffer)
+		if n > 0 {
+			accumulated.Write(buffer[:n])
+		}
+		if done, _ := scanner.scan(accumulated.String()); done {
+			return true
+		}
+		if n == 0 {
+			time.Sleep(BUFFER_POLL_RATE)
+		}
+	}
+	return false
+}
+
+func partialOutput(bs *BashSession, accumulated string, command string) string {
+	output := bs.cleanOutput(accumulated, command)
+	if output == "" {
+		return ""
+	}
+	return fmt.Sprintf("\nOutput so far:\n%v", bs.truncateOutput(output))
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package bash
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+func pollable(f *os.File) (*os.File, error) {
+	return nil, fmt.Errorf("Bash sessions are not supported on %v", runtime.GOOS)
+}
+
+func setWindowSize(f *os.File, rows uint16, cols uint16) error {
+	return fmt.Errorf("Bash sessions are not supported on %v", runtime.GOOS)
+}
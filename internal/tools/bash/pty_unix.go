@@ -0,0 +1,50 @@
+//go:build unix
+
+package bash
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/creack/pty"
+)
+
+// pollable returns a non-blocking copy of the pty master so read deadlines
+// work. pty leaves the master in blocking mode, where SetReadDeadline fails
+// and reads block until output arrives.
+func pollable(f *os.File) (*os.File, error) {
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
+
+// setWindowSize resizes the pty without calling f.Fd(), which would switch the
+// file to blocking mode and break read deadlines
+func setWindowSize(f *os.File, rows uint16, cols uint16) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	size := pty.Winsize{Rows: rows, Cols: cols}
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&size)))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
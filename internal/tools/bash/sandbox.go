@@ -0,0 +1,15 @@
+package bash
+
+// SandboxConfig describes the isolation applied to a sandboxed BashSession.
+// The workspace is bind-mounted read-write, the rest of the filesystem is
+// mounted read-only and networking is disabled unless AllowNetwork is set.
+type SandboxConfig struct {
+	Workspace    string
+	AllowNetwork bool
+}
+
+func WithSandbox(cfg SandboxConfig) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.sandbox = &cfg
+	}
+}
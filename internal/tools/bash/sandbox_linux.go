@@ -0,0 +1,82 @@
+package bash
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// Runs as PID 1 of the new namespaces, builds a read-only view of the host
+// filesystem with the workspace mounted read-write, then chroots into it and
+// execs the interactive shell from the working directory.
+const SANDBOX_INIT_SCRIPT = `set -e
+ws=$1
+dir=$2
+shift 2
+root=$(mktemp -d)
+mount --make-rprivate /
+mount --rbind / "$root"
+# Every mount must become read-only, otherwise the sandbox is not started.
+# Its other flags are kept, as a user namespace cannot clear them.
+awk -v r="$root" '$5 == r || index($5, r "/") == 1 { print $5, $6 }' /proc/self/mountinfo |
+	while read -r m o; do
+		case $o in *,*) o=,${o#*,} ;; *) o= ;; esac
+		mount -o "remount,bind,ro$o" "$m" || { echo "go-agent-sandbox: unable to make $m read-only" >&2; exit 1; }
+	done
+mount -t proc proc "$root/proc"
+mount -t tmpfs tmpfs "$root/tmp"
+mkdir -p "$root$ws"
+mount --bind "$ws" "$root$ws"
+ip link set lo up 2>/dev/null || true
+exec chroot "$root" /bin/sh -c 'cd "$0" && exec "$@"' "$dir" "$@"
+`
+
+func sandboxCommand(cmd *exec.Cmd, cfg SandboxConfig) (*exec.Cmd, error) {
+	workspace := cfg.Workspace
+	if workspace == "" {
+		var err error
+		workspace, err = os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+	}
+	workspace, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(workspace); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("Sandbox workspace '%v' is not a directory", workspace)
+	}
+
+	dir := workspace
+	if cmd.Dir != "" {
+		if dir, err = filepath.Abs(cmd.Dir); err != nil {
+			return nil, err
+		}
+	}
+
+	args := append([]string{"--norc", "--noprofile", "-c", SANDBOX_INIT_SCRIPT, "go-agent-sandbox", workspace, dir}, cmd.Args...)
+	sandboxed := exec.Command("bash", args...)
+	sandboxed.Env = cmd.Env
+	sandboxed.Dir = workspace
+
+	flags := syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID
+	if !cfg.AllowNetwork {
+		flags |= syscall.CLONE_NEWNET
+	}
+
+	sandboxed.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: uintptr(flags),
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getuid(), Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getgid(), Size: 1},
+		},
+		GidMappingsEnableSetgroups: false,
+	}
+
+	return sandboxed, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package bash
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+func sandboxCommand(cmd *exec.Cmd, cfg SandboxConfig) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("Sandboxed bash sessions are not supported on %v", runtime.GOOS)
+}
@@ -0,0 +1,20 @@
+package bash_test
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/frozenkro/go-agent/internal/tools/bash"
+	"github.com/frozenkro/go-agent/internal/tools/bash/bashtest"
+)
+
+func TestSessionScriptedTTY(t *testing.T) {
+	bashtest.CheckSession(t, bash.WithTTY(bashtest.ScriptedTTY()))
+}
+
+func TestSessionRealBash(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	bashtest.CheckSession(t)
+}
@@ -0,0 +1,37 @@
+package bash
+
+// WithOutputHandler registers a function called with command output as it
+// arrives, before the command has finished. Output is normalized the same way
+// as the final result unless raw output is enabled.
+func WithOutputHandler(handler func(chunk string)) BashSessionOption {
+	return func(bs *BashSession) {
+		bs.outputHandler = handler
+	}
+}
+
+// outputStream forwards pty output to the session's output handler, holding
+// back escape sequences split across reads so they can be removed whole
+type outputStream struct {
+	bs      *BashSession
+	pending string
+}
+
+func (s *outputStream) write(chunk []byte) {
+	if s.bs.outputHandler == nil {
+		return
+	}
+
+	data := s.pending + string(chunk)
+	s.pending = ""
+	if i := incompleteEscape(data); i >= 0 {
+		s.pending = data[i:]
+		data = data[:i]
+	}
+
+	if !s.bs.rawOutput {
+		data = normalizeTerminalOutput(data)
+	}
+	if data != "" {
+		s.bs.outputHandler(data)
+	}
+}
@@ -0,0 +1,154 @@
+package bash
+
+import (
+	"strings"
+)
+
+const ESC byte = 0x1b
+
+// WithRawOutput disables terminal output normalization and the TERM=dumb and
+// NO_COLOR environment defaults, returning output exactly as the pty emitted it.
+func WithRawOutput() BashSessionOption {
+	return func(bs *BashSession) {
+		bs.rawOutput = true
+	}
+}
+
+// normalizeTerminalOutput renders pty output roughly the way a terminal would
+// display it: escape sequences are removed, carriage returns and backspaces
+// overwrite earlier characters on the line, and line endings become "\n".
+func normalizeTerminalOutput(output string) string {
+	stripped := stripEscapeSequences(output)
+	stripped = strings.ReplaceAll(stripped, "\r\n", "\n")
+
+	lines := strings.Split(stripped, "\n")
+	for i, line := range lines {
+		lines[i] = renderLine(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stripEscapeSequences removes CSI, OSC and other ESC-initiated sequences
+func stripEscapeSequences(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != ESC {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 >= len(s) {
+			break
+		}
+
+		switch s[i+1] {
+		case '[':
+			// CSI: parameter and intermediate bytes, terminated by a final byte in 0x40-0x7e
+			j := i + 2
+			for j < len(s) && (s[j] < 0x40 || s[j] > 0x7e) {
+				j++
+			}
+			i = j
+		case ']', 'P', '_', '^':
+			// OSC, DCS, APC and PM: terminated by BEL or ST (ESC \)
+			j := i + 2
+			for j < len(s) {
+				if s[j] == 0x07 {
+					break
+				}
+				if s[j] == ESC && j+1 < len(s) && s[j+1] == '\\' {
+					j++
+					break
+				}
+				j++
+			}
+			i = j
+		case '(', ')', '*', '+', '#', '%':
+			// Character set designation and similar two byte sequences
+			i += 2
+		default:
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// renderLine applies carriage returns and backspaces within a single line and
+// drops any remaining control characters other than tabs.
+func renderLine(line string) string {
+	if !strings.ContainsAny(line, "\r\b") && !hasControlChars(line) {
+		return line
+	}
+
+	rendered := []rune{}
+	cursor := 0
+	for _, r := range line {
+		switch {
+		case r == '\r':
+			cursor = 0
+		case r == '\b':
+			if cursor > 0 {
+				cursor--
+			}
+		case r < 0x20 && r != '\t', r == 0x7f:
+			continue
+		default:
+			if cursor < len(rendered) {
+				rendered[cursor] = r
+			} else {
+				rendered = append(rendered, r)
+			}
+			cursor++
+		}
+	}
+
+	return string(rendered)
+}
+
+func hasControlChars(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < 0x20 && s[i] != '\t') || s[i] == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// incompleteEscape returns the index of an escape sequence at the end of s
+// that has not been terminated yet, or -1 if there is none
+func incompleteEscape(s string) int {
+	i := strings.LastIndexByte(s, ESC)
+	if i < 0 {
+		return -1
+	}
+	seq := s[i:]
+	if len(seq) < 2 {
+		return i
+	}
+
+	switch seq[1] {
+	case '[':
+		for j := 2; j < len(seq); j++ {
+			if seq[j] >= 0x40 && seq[j] <= 0x7e {
+				return -1
+			}
+		}
+		return i
+	case ']', 'P', '_', '^':
+		if strings.ContainsRune(seq, 0x07) {
+			return -1
+		}
+		// ST (ESC \) would have been found as the last ESC
+		return i
+	case '\\':
+		// ST terminating an earlier sequence
+		return -1
+	case '(', ')', '*', '+', '#', '%':
+		if len(seq) < 3 {
+			return i
+		}
+	}
+	return -1
+}
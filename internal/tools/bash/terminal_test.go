@@ -0,0 +1,62 @@
+package bash
+
+import "testing"
+
+func TestNormalizeTerminalOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"plain text", "hello", "hello"},
+		{"colors", "\x1b[01;34mdir\x1b[0m file", "dir file"},
+		{"cursor movement", "\x1b[2K\x1b[1Gdone", "done"},
+		{"unterminated csi", "ok\x1b[31", "ok"},
+		{"osc terminated by bel", "\x1b]0;title\x07text", "text"},
+		{"osc terminated by st", "\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"character set", "\x1b(Bok", "ok"},
+		{"two byte sequence", "\x1b=ok", "ok"},
+		{"trailing esc", "ok\x1b", "ok"},
+		{"crlf", "a\r\nb\r\n", "a\nb\n"},
+		{"progress redraw", "10%\r50%\r100%\r\ndone", "100%\ndone"},
+		{"shorter overwrite", "hello\rab", "abllo"},
+		{"backspaces", "abc\b\bX", "aXc"},
+		{"backspace at line start", "\bab", "ab"},
+		{"multibyte overwrite", "日本\rx", "x本"},
+		{"control characters", "a\x00b\x07c\x7f", "abc"},
+		{"tabs kept", "a\tb", "a\tb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeTerminalOutput(tt.output); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIncompleteEscape(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   int
+	}{
+		{"no escape", "abc", -1},
+		{"lone esc", "abc\x1b", 3},
+		{"partial csi", "abc\x1b[31", 3},
+		{"complete csi", "abc\x1b[31m", -1},
+		{"partial osc", "a\x1b]0;title", 1},
+		{"osc terminated by bel", "a\x1b]0;title\x07", -1},
+		{"osc terminated by st", "a\x1b]0;title\x1b\\", -1},
+		{"partial character set", "a\x1b(", 1},
+		{"complete character set", "a\x1b(B", -1},
+		{"two byte sequence", "a\x1b=", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := incompleteEscape(tt.output); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+	toolschema "github.com/frozenkro/go-agent/models/anthropic/tool_schema"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	DEFAULT_MAX_CHARACTERS int = 10000
+	VIEW_DEPTH             int = 2
+	MAX_IMAGE_SIZE         int = 5 * 1024 * 1024 // Largest image accepted by the API
+)
+
+// TextEditorTool implements the view, create, str_replace and insert commands
+// of Anthropic's text editor tool on the local filesystem
+type TextEditorTool struct {
+	workingDir    string
+	allowedDirs   []string
+	maxCharacters int
+}
+
+type TextEditorOption func(*TextEditorTool)
+
+// WithWorkingDir sets the directory relative paths are resolved against.
+// Defaults to the current directory.
+func WithWorkingDir(dir string) TextEditorOption {
+	return func(t *TextEditorTool) {
+		t.workingDir = dir
+	}
+}
+
+// WithAllowedDirs restricts the editor to files inside dirs, e.g. the
+// workspace of a sandboxed shell
+func WithAllowedDirs(dirs ...string) TextEditorOption {
+	return func(t *TextEditorTool) {
+		t.allowedDirs = append(t.allowedDirs, dirs...)
+	}
+}
+
+// WithMaxCharacters limits the output of view. A limit of 0 disables truncation.
+func WithMaxCharacters(limit int) TextEditorOption {
+	return func(t *TextEditorTool) {
+		t.maxCharacters = limit
+	}
+}
+
+func NewTextEditorTool(opts ...TextEditorOption) *TextEditorTool {
+	t := &TextEditorTool{
+		maxCharacters: DEFAULT_MAX_CHARACTERS,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Invoke runs an editor command. Viewing an image returns it as an image
+// block; every other result is text.
+func (t *TextEditorTool) Invoke(params any) ([]anthropic.Content, error) {
+	var p toolschema.TextEditorToolInput
+	err := mapstructure.Decode(params, &p)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse invoke params for TextEditorTool: '%v'", params)
+	}
+
+	path, err := t.resolve(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Command == toolschema.EDITOR_VIEW {
+		return t.view(path, p.ViewRange)
+	}
+	result, err := t.edit(path, p)
+	if err != nil {
+		return nil, err
+	}
+	return []anthropic.Content{anthropic.NewTextContent(result)}, nil
+}
+
+func (t *TextEditorTool) edit(path string, p toolschema.TextEditorToolInput) (string, error) {
+	switch p.Command {
+	case toolschema.EDITOR_CREATE:
+		return t.create(path, p.FileText)
+	case toolschema.EDITOR_STR_REPLACE:
+		return t.strReplace(path, p.OldStr, p.NewStr)
+	case toolschema.EDITOR_INSERT:
+		text := p.InsertText
+		if text == "" {
+			text = p.NewStr
+		}
+		return t.insert(path, p.InsertLine, text)
+	default:
+		return "", fmt.Errorf("Unknown text editor command '%v'. Use view, create, str_replace or insert", p.Command)
+	}
+}
+
+func (t *TextEditorTool) resolve(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("A path is required")
+	}
+	if !filepath.IsAbs(path) {
+		dir := t.workingDir
+		if dir == "" {
+			var err error
+			if dir, err = os.Getwd(); err != nil {
+				return "", err
+			}
+		}
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+
+	if len(t.allowedDirs) == 0 {
+		return path, nil
+	}
+	// Symlinks are followed so a link in an allowed directory cannot point outside it
+	real := resolveSymlinks(path)
+	for _, dir := range t.allowedDirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(resolveSymlinks(abs), real); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("Path '%v' is outside the directories the editor may access", path)
+}
+
+// resolveSymlinks resolves the symlinks in the longest existing prefix of
+// path, which may name a file that does not exist yet
+func resolveSymlinks(path string) string {
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(real, rest)
+		}
+		if filepath.Dir(dir) == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+func (t *TextEditorTool) view(path string, viewRange []int) ([]anthropic.Content, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		if len(viewRange) > 0 {
+			return nil, fmt.Errorf("view_range cannot be used when viewing a directory")
+		}
+		listing, err := t.viewDir(path)
+		if err != nil {
+			return nil, err
+		}
+		return []anthropic.Content{anthropic.NewTextContent(listing)}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mediaType := anthropic.DetectMediaType(path, data)
+	if anthropic.IsImageMediaType(mediaType) {
+		if len(data) > MAX_IMAGE_SIZE {
+			return nil, fmt.Errorf("'%v' is %v bytes, larger than the %v bytes allowed for images", path, len(data), MAX_IMAGE_SIZE)
+		}
+		return []anthropic.Content{anthropic.NewImageContent(mediaType, data)}, nil
+	}
+
+	text, err := t.viewText(data, viewRange)
+	if err != nil {
+		return nil, err
+	}
+	return []anthropic.Content{anthropic.NewTextContent(text)}, nil
+}
+
+func (t *TextEditorTool) viewText(data []byte, viewRange []int) (string, error) {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	start, end := 1, len(lines)
+	if len(viewRange) > 0 {
+		if len(viewRange) != 2 {
+			return "", fmt.Errorf("view_range must contain a start and end line")
+		}
+		start = viewRange[0]
+		if viewRange[1] != -1 {
+			end = viewRange[1]
+		}
+		if start < 1 || start > len(lines) || end < start || end > len(lines) {
+			return "", fmt.Errorf("Invalid view_range %v for a file with %v lines", viewRange, len(lines))
+		}
+	}
+
+	var out strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&out, "%6d\t%v\n", i, lines[i-1])
+	}
+	return t.truncate(out.String()), nil
+}
+
+func (t *TextEditorTool) viewDir(path string) (string, error) {
+	entries := []string{}
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == path {
+			return err
+		}
+		rel, _ := filepath.Rel(path, p)
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			entries = append(entries, rel+"/")
+			if strings.Count(rel, string(filepath.Separator))+1 >= VIEW_DEPTH {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		entries = append(entries, rel)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	out := fmt.Sprintf("Files and directories up to %v levels deep in %v, excluding hidden items:\n%v", VIEW_DEPTH, path, strings.Join(entries, "\n"))
+	return t.truncate(out), nil
+}
+
+func (t *TextEditorTool) create(path string, text string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("File created successfully at: %v", path), nil
+}
+
+func (t *TextEditorTool) strReplace(path string, oldStr string, newStr string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	content := string(data)
+
+	switch n := strings.Count(content, oldStr); {
+	case oldStr == "":
+		return "", fmt.Errorf("old_str must not be empty")
+	case n == 0:
+		return "", fmt.Errorf("No match found for replacement. Check that old_str matches the file exactly, including whitespace")
+	case n > 1:
+		return "", fmt.Errorf("Found %v matches for replacement text. Include more context in old_str to make the match unique", n)
+	}
+
+	if err := writePreservingMode(path, strings.Replace(content, oldStr, newStr, 1)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("The file %v has been edited successfully.", path), nil
+}
+
+func (t *TextEditorTool) insert(path string, line int, text string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	count := len(lines)
+	if strings.HasSuffix(string(data), "\n") {
+		count--
+	}
+	if line < 0 || line > count {
+		return "", fmt.Errorf("Invalid insert_line %v for a file with %v lines", line, count)
+	}
+	lines = slices.Insert(lines, line, strings.Split(text, "\n")...)
+
+	if err := writePreservingMode(path, strings.Join(lines, "\n")); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("The file %v has been edited successfully.", path), nil
+}
+
+// truncate cuts output after maxCharacters runes, so a multi-byte character
+// is never split
+func (t *TextEditorTool) truncate(output string) string {
+	if t.maxCharacters <= 0 {
+		return output
+	}
+	characters := 0
+	for i := range output {
+		if characters == t.maxCharacters {
+			return output[:i] + fmt.Sprintf("\n[Output truncated to %v characters. Use view_range to view the rest]", t.maxCharacters)
+		}
+		characters++
+	}
+	return output
+}
+
+func writePreservingMode(path string, content string) error {
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), mode)
+}
@@ -0,0 +1,50 @@
+package editor_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frozenkro/go-agent/internal/tools/editor"
+	"github.com/frozenkro/go-agent/models/anthropic"
+	toolschema "github.com/frozenkro/go-agent/models/anthropic/tool_schema"
+)
+
+func TestViewTruncatesCharacters(t *testing.T) {
+	const note = "\n[Output truncated to %v characters. Use view_range to view the rest]"
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	// Every line is prefixed with its number, "     1\t" is 7 characters
+	if err := os.WriteFile(path, []byte("日本語のテキスト"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  string
+	}{
+		{"within the limit", 16, "     1\t日本語のテキスト\n"},
+		{"exactly the limit", 15, "     1\t日本語のテキスト"},
+		{"cut between characters", 9, "     1\t日本"},
+		{"no limit", 0, "     1\t日本語のテキスト\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := editor.NewTextEditorTool(editor.WithMaxCharacters(tt.limit))
+			contents, err := tool.Invoke(map[string]any{"command": toolschema.EDITOR_VIEW, "path": path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := contents[0].(*anthropic.TextContent).Text
+			got, truncated := strings.CutSuffix(got, fmt.Sprintf(note, tt.limit))
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+			if wantTruncated := !strings.HasSuffix(tt.want, "\n"); truncated != wantTruncated {
+				t.Errorf("Expected truncated to be %v, got %v", wantTruncated, truncated)
+			}
+		})
+	}
+}
@@ -1,5 +1,46 @@
 package tools
 
+import (
+	"io"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// Tool runs a tool use and returns the text and image blocks of its result
 type Tool interface {
+	Invoke(params any) ([]anthropic.Content, error)
+}
+
+// TextTool is a tool whose result is plain text
+type TextTool interface {
 	Invoke(params any) (string, error)
 }
+
+// FromTextTool adapts a TextTool to Tool. Closing the adapter closes t if it
+// implements io.Closer.
+func FromTextTool(t TextTool) Tool {
+	return textToolAdapter{t}
+}
+
+type textToolAdapter struct {
+	tool TextTool
+}
+
+func (a textToolAdapter) Invoke(params any) ([]anthropic.Content, error) {
+	result, err := a.tool.Invoke(params)
+	if err != nil {
+		return nil, err
+	}
+	// The API rejects empty text blocks
+	if result == "" {
+		return nil, nil
+	}
+	return []anthropic.Content{anthropic.NewTextContent(result)}, nil
+}
+
+func (a textToolAdapter) Close() error {
+	if closer, ok := a.tool.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package tools
+
+import (
+	"github.com/frozenkro/go-agent/internal/tools/bash"
+	"github.com/frozenkro/go-agent/internal/tools/editor"
+)
+
+type ToolConfig struct {
+	BashOptions   []bash.BashSessionOption
+	EditorOptions []editor.TextEditorOption
+}
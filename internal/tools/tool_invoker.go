@@ -1,13 +1,18 @@
 package tools
 
-import "github.com/frozenkro/go-agent/models/anthropic"
+import (
+	"errors"
+	"io"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
 
 type ToolInvoker struct {
 	ToolMap *ToolMap
 }
 
-func NewToolInvoker() ToolInvoker {
-	toolMap := InitToolMap()
+func NewToolInvoker(cfg ToolConfig) ToolInvoker {
+	toolMap := InitToolMap(cfg)
 	return ToolInvoker{
 		ToolMap: toolMap,
 	}
@@ -19,14 +24,28 @@ func (t *ToolInvoker) Invoke(toolUseContent anthropic.ToolUseContent) (anthropic
 		return anthropic.ToolResultContent{}, err
 	}
 	result, err := toolMeta.Tool.Invoke(toolUseContent.Input)
-	if err != nil {
-		return anthropic.ToolResultContent{}, err
-	}
 
 	toolResultContent := anthropic.ToolResultContent{
 		BaseContent: anthropic.BaseContent{Type: anthropic.TOOL_RESULT},
 		ToolUseId:   toolUseContent.Id,
 		Content:     result,
 	}
+	if err != nil {
+		// Report failures to the model so it can adjust, rather than ending the run
+		toolResultContent.Content = []anthropic.Content{anthropic.NewTextContent(err.Error())}
+		toolResultContent.IsError = true
+	}
 	return toolResultContent, nil
 }
+
+// Close releases resources held by tools, such as shell sessions and
+// background jobs
+func (t *ToolInvoker) Close() error {
+	errs := []error{}
+	for _, meta := range t.ToolMap.Map {
+		if closer, ok := meta.Tool.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
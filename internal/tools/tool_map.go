@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/frozenkro/go-agent/internal/tools/bash"
+	"github.com/frozenkro/go-agent/internal/tools/editor"
 	"github.com/frozenkro/go-agent/models/anthropic"
 )
 
@@ -17,18 +18,29 @@ type ToolMap struct {
 	Map map[anthropic.ToolName]ToolMeta
 }
 
-func InitToolMap() *ToolMap {
+func InitToolMap(cfg ToolConfig) *ToolMap {
 	toolNameMap := make(map[anthropic.ToolName]ToolMeta)
 
+	bashTool := bash.NewBashTool(cfg.BashOptions...)
 	toolNameMap[anthropic.BASH] = ToolMeta{
 		Name: anthropic.BASH,
 		Spec: anthropic.NewBashTool(),
-		Tool: bash.BashTool{},
+		Tool: FromTextTool(bashTool),
+	}
+	toolNameMap[anthropic.BASH_SESSION] = ToolMeta{
+		Name: anthropic.BASH_SESSION,
+		Spec: anthropic.NewBashSessionTool(),
+		Tool: FromTextTool(bashTool.SessionTool()),
+	}
+	toolNameMap[anthropic.BASH_JOB] = ToolMeta{
+		Name: anthropic.BASH_JOB,
+		Spec: anthropic.NewBashJobTool(),
+		Tool: FromTextTool(bashTool.JobTool()),
 	}
 	toolNameMap[anthropic.TEXT_EDITOR] = ToolMeta{
 		Name: anthropic.TEXT_EDITOR,
 		Spec: anthropic.NewTextEditorTool(),
-		Tool: nil, //TODO
+		Tool: editor.NewTextEditorTool(cfg.EditorOptions...),
 	}
 
 	return &ToolMap{
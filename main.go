@@ -1,117 +1,322 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
+	"strings"
 
 	"github.com/frozenkro/go-agent/agents"
+	"github.com/frozenkro/go-agent/clients"
+	"github.com/frozenkro/go-agent/internal/tools/bash"
+	"github.com/frozenkro/go-agent/internal/tools/editor"
 	"github.com/frozenkro/go-agent/models/anthropic"
 	"github.com/joho/godotenv"
 )
 
-const ANTHROPIC_MESSAGES_URL = "https://api.anthropic.com/v1/messages"
-const TEST_PROMPT = "List all files in the current directory"
-
 type AnthropicHandler interface {
 	HandleResponse(anthropic.MessagesResponse) (anthropic.AnthropicMessagesRequest, bool, error)
 	GetRequest(anthropic.Model, string, ...agents.AnthropicAgentOption)
 }
 
 func main() {
-	var (
-		request  *anthropic.AnthropicMessagesRequest
-		response *anthropic.MessagesResponse
-		done     bool
-	)
-
-	ctx := context.Background()
-	godotenv.Load()
+	if len(os.Args) > 1 && os.Args[1] == "batch" {
+		godotenv.Load()
+		if err := runBatch(os.Args[2:]); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "models" {
+		godotenv.Load()
+		if err := runModels(); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
 
-	anthropicAgent, err := agents.NewAnthropicAgent(anthropic.SONNET_4, TEST_PROMPT, agents.WithTools(anthropic.BASH))
-	if err != nil {
+	if err := run(); err != nil {
 		log.Fatal(err.Error())
 	}
+}
 
-	request = anthropicAgent.GetRequest()
-
-	for {
-		reqJson, err := json.Marshal(request)
+// run runs the agent with the flags given. Errors are returned rather than
+// fatal so deferred cleanup, such as ending shell sessions, still happens.
+func run() error {
+	model := flag.String("model", string(anthropic.SONNET_4), "Model to run the agent with")
+	sandbox := flag.Bool("sandbox", false, "Run the agent's shell in isolated namespaces with a read-only filesystem")
+	workspace := flag.String("workspace", "", "Directory mounted read-write in the sandbox (defaults to the current directory)")
+	allowNetwork := flag.Bool("allow-network", false, "Allow network access from the sandboxed shell")
+	shell := flag.String("shell", "bash", "Shell binary used for the bash tool")
+	workdir := flag.String("workdir", "", "Working directory of the agent's shell")
+	initScript := flag.String("init-script", "", "Script sourced when the agent's shell starts")
+	maxTokens := flag.Int("max-tokens", 0, "Maximum tokens generated per response, including thinking (defaults to the model's default)")
+	thinking := flag.Int("thinking", 0, "Enable extended thinking with this token budget")
+	interleavedThinking := flag.Bool("interleaved-thinking", false, "Allow thinking between tool calls")
+	showThinking := flag.Bool("show-thinking", false, "Print the model's thinking to stderr")
+	webSearch := flag.Bool("web-search", false, "Let the model search the web")
+	webFetch := flag.Bool("web-fetch", false, "Let the model fetch web pages")
+	codeExecution := flag.Bool("code-execution", false, "Let the model run code in Anthropic's sandbox")
+	citations := flag.Bool("citations", false, "Cite the attached documents in the answer")
+	attachments := []anthropic.Content{}
+	flag.Func("attach", "Attach an image, PDF or text file to the prompt (repeatable)", func(path string) error {
+		content, err := agents.LoadAttachment(path)
 		if err != nil {
-			log.Fatal(err.Error())
+			return err
 		}
+		attachments = append(attachments, content)
+		return nil
+	})
+	uploads := []string{}
+	flag.Func("upload", "Upload a file with the Files API and attach it to the prompt (repeatable)", func(path string) error {
+		uploads = append(uploads, path)
+		return nil
+	})
+	system := flag.String("system", "", "System prompt")
+	systemFile := flag.String("system-file", "", "File with the system prompt, rendered as a Go template with the cwd, OS, date and tools")
+	noProjectInstructions := flag.Bool("no-project-instructions", false, "Do not add "+agents.PROJECT_INSTRUCTIONS_FILE+" files found from the working directory up to the system prompt")
+	toolChoice := flag.String("tool-choice", "", "Tool choice: auto or none for every turn, or any or the name of a tool to force tool use on the first turn")
+	noParallelTools := flag.Bool("no-parallel-tools", false, "Allow at most one tool call per response")
+	outputDir := flag.String("output-dir", "", "Directory to save files written by code execution")
+	var temperature, topP *float64
+	flag.Func("temperature", "Randomness of responses, from 0 to 1", setOptionalFloat(&temperature))
+	flag.Func("top-p", "Nucleus sampling threshold, from 0 to 1", setOptionalFloat(&topP))
+	topK := flag.Int("top-k", 0, "Sample only from the k most likely tokens")
+	stopSequences := []string{}
+	flag.Func("stop", "End responses at this sequence (repeatable)", func(sequence string) error {
+		stopSequences = append(stopSequences, sequence)
+		return nil
+	})
+	userId := flag.String("user-id", "", "Opaque id of the end user sent in the request metadata")
+	serviceTier := flag.String("service-tier", "", "Service tier: auto or standard_only")
+	config := flag.String("config", "", "JSON file of flag values, e.g. {\"temperature\": 0.2} (defaults to "+DEFAULT_CONFIG_FILE+" if present)")
+	flag.Parse()
 
-		resBytes, err := postMessage(ctx, string(reqJson))
-		if err != nil {
-			log.Fatal(err.Error())
-		}
-		err = checkMessagesResponseErr(resBytes)
-		if err != nil {
-			log.Fatal(err.Error())
-		}
+	prompt := strings.Join(flag.Args(), " ")
+	if strings.TrimSpace(prompt) == "" {
+		return fmt.Errorf("Usage: go-agent [flags] <prompt>")
+	}
 
-		response = &anthropic.MessagesResponse{}
-		err = json.Unmarshal(resBytes, response)
-		if err != nil {
-			log.Fatal(err.Error())
-		}
+	configPath := *config
+	if configPath == "" {
+		configPath = DEFAULT_CONFIG_FILE
+	}
+	if err := applyConfig(flag.CommandLine, configPath, *config != ""); err != nil {
+		return err
+	}
 
-		request, done, err = anthropicAgent.HandleResponse(response)
-		if done {
-			break
-		}
+	ctx := context.Background()
+	godotenv.Load()
+	client := clients.NewAnthropicClient()
+	modelInfo, err := lookupModel(ctx, client, anthropic.Model(*model))
+	if err != nil {
+		return err
+	}
+
+	files := []anthropic.File{}
+	for _, path := range uploads {
+		file, err := client.UploadFileFromPath(ctx, path)
 		if err != nil {
-			log.Fatal(err.Error())
+			return fmt.Errorf("Unable to upload '%v': %w", path, err)
 		}
+		files = append(files, *file)
 	}
-}
 
-func postMessage(ctx context.Context, body string) ([]byte, error) {
-	apiKey := os.Getenv("GA_ANTHROPIC_API_KEY")
-	bodyReader := bytes.NewReader([]byte(body))
+	opts := []agents.AnthropicAgentOption{
+		agents.WithTools(supportedTools(modelInfo)...),
+		agents.WithWorkingDir(*workdir),
+		agents.WithBashOptions(bash.WithShell(*shell), bash.WithInitScript(*initScript)),
+		agents.WithTextEditorOptions(editor.WithAllowedDirs(editorDir(*sandbox, *workspace, *workdir))),
+		agents.WithMaxTokens(maxTokensFor(modelInfo, *maxTokens)),
+		agents.WithAttachments(attachments...),
+		agents.WithFiles(files...),
+		agents.WithClient(client),
+		agents.WithSystemPromptCache(anthropic.TTL_5m),
+	}
+	if *citations {
+		opts = append(opts, agents.WithCitations())
+	}
+	if *webSearch {
+		opts = append(opts, agents.WithServerTools(anthropic.NewWebSearchTool()))
+	}
+	if *webFetch {
+		opts = append(opts, agents.WithServerTools(anthropic.NewWebFetchTool()))
+	}
+	if *codeExecution {
+		opts = append(opts, agents.WithServerTools(anthropic.NewCodeExecutionTool()))
+	}
+	if *thinking > 0 {
+		opts = append(opts, agents.WithThinking(*thinking))
+	}
+	if *interleavedThinking {
+		opts = append(opts, agents.WithInterleavedThinking())
+	}
+	if temperature != nil {
+		opts = append(opts, agents.WithTemperature(*temperature))
+	}
+	if topP != nil {
+		opts = append(opts, agents.WithTopP(*topP))
+	}
+	if *topK > 0 {
+		opts = append(opts, agents.WithTopK(*topK))
+	}
+	if len(stopSequences) > 0 {
+		opts = append(opts, agents.WithStopSequences(stopSequences...))
+	}
+	if *userId != "" {
+		opts = append(opts, agents.WithUserId(*userId))
+	}
+	if *serviceTier != "" {
+		opts = append(opts, agents.WithServiceTier(anthropic.ServiceTier(*serviceTier)))
+	}
+	if *system != "" {
+		opts = append(opts, agents.WithSystemPrompt(*system))
+	}
+	if *systemFile != "" {
+		opts = append(opts, agents.WithSystemPromptFile(*systemFile))
+	}
+	if !*noProjectInstructions {
+		opts = append(opts, agents.WithProjectInstructions(""))
+	}
+	// Forcing tool use applies to the first turn only, so the model can still end its turn
+	switch choice := anthropic.ToolChoiceType(*toolChoice); choice {
+	case "":
+	case anthropic.TOOL_CHOICE_AUTO, anthropic.TOOL_CHOICE_NONE:
+		opts = append(opts, agents.WithToolChoice(anthropic.NewToolChoice(choice)))
+	case anthropic.TOOL_CHOICE_ANY:
+		opts = append(opts, agents.WithTurnToolChoice(0, anthropic.NewToolChoice(choice)))
+	default:
+		opts = append(opts, agents.WithForcedTool(anthropic.ToolName(*toolChoice)))
+	}
+	if *noParallelTools {
+		opts = append(opts, agents.WithDisableParallelToolUse())
+	}
+	if *sandbox {
+		opts = append(opts, agents.WithBashOptions(bash.WithSandbox(bash.SandboxConfig{
+			Workspace:    *workspace,
+			AllowNetwork: *allowNetwork,
+		})))
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ANTHROPIC_MESSAGES_URL, bodyReader)
+	anthropicAgent, err := agents.NewAnthropicAgent(modelInfo.Model, prompt, opts...)
 	if err != nil {
-		log.Fatalf("%v", err.Error())
+		return err
 	}
 
-	req.Header.Add("x-api-key", apiKey)
-	req.Header.Add("anthropic-version", "2023-06-01")
-	req.Header.Add("content-type", "application/json")
+	defer anthropicAgent.Close()
+
+	events, unsubscribe := anthropicAgent.Subscribe()
+	rendered := make(chan struct{})
+	go func() {
+		renderEvents(events, *showThinking)
+		close(rendered)
+	}()
 
-	res, err := http.DefaultClient.Do(req)
+	result, err := anthropicAgent.Run(ctx)
+	// Let the renderer finish printing tool output before the result
+	unsubscribe()
+	<-rendered
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer res.Body.Close()
-
-	content, err := io.ReadAll(res.Body)
+	fmt.Println(renderResult(result))
+	if len(result.OutputFiles) == 0 {
+		return nil
+	}
+	if *outputDir == "" {
+		fmt.Printf("\nOutput files: %v\n", strings.Join(result.OutputFiles, ", "))
+		return nil
+	}
+	paths, err := agents.SaveOutputFiles(ctx, client, result, *outputDir)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	fmt.Printf("\nSaved output files: %v\n", strings.Join(paths, ", "))
+	return nil
+}
 
-	log.Printf("Response:\n%v", string(content))
-	return content, nil
+// supportedTools returns the local tools to enable, leaving out Anthropic-
+// defined tools whose version the model does not accept
+func supportedTools(info anthropic.ModelInfo) []anthropic.ToolName {
+	names := []anthropic.ToolName{anthropic.BASH_SESSION, anthropic.BASH_JOB}
+	defined := []anthropic.AnthropicToolSpec{anthropic.NewBashTool(), anthropic.NewTextEditorTool()}
+	for _, spec := range defined {
+		if info.SupportsToolType(spec.GetType()) {
+			names = append(names, spec.GetName())
+		} else {
+			log.Printf("Tool '%v' is disabled as %v does not support %v", spec.GetName(), info.Model, spec.GetType())
+		}
+	}
+	return names
 }
 
-func checkMessagesResponseErr(data []byte) error {
-	baseRes := &anthropic.MessagesBaseResponse{}
-	if err := json.Unmarshal(data, baseRes); err != nil {
-		return err
+// editorDir is the only directory the text editor may change: the sandbox
+// workspace when the shell is sandboxed and the working directory otherwise,
+// each defaulting to the current directory
+func editorDir(sandbox bool, workspace string, workdir string) string {
+	dir := workdir
+	if sandbox {
+		dir = workspace
 	}
+	if dir == "" {
+		dir = "."
+	}
+	return dir
+}
 
-	if baseRes.Type == "error" {
-		errRes := &anthropic.MessagesErrorResponse{}
-		if err := json.Unmarshal(data, errRes); err != nil {
-			return err
+// renderResult marks cited parts of the answer with [n] and lists the cited
+// document spans after it
+func renderResult(result *agents.RunResult) string {
+	if len(result.Citations) == 0 {
+		return result.Text
+	}
+
+	var text, sources strings.Builder
+	last, n := 0, 0
+	for _, span := range result.Citations {
+		text.WriteString(result.Text[last:span.End])
+		last = span.End
+		for _, c := range span.Citations {
+			n++
+			fmt.Fprintf(&text, "[%v]", n)
+			title := c.DocumentTitle
+			if c.Type == anthropic.WEB_SEARCH_LOCATION {
+				title = c.Title
+			} else if title == "" {
+				title = fmt.Sprintf("document %v", c.DocumentIndex)
+			}
+			fmt.Fprintf(&sources, "\n[%v] %v, %v: %q", n, title, c.Location(), strings.TrimSpace(c.CitedText))
 		}
+	}
+	text.WriteString(result.Text[last:])
+	return text.String() + "\n\nSources:" + sources.String()
+}
 
-		return fmt.Errorf("Anthropic error {type: '%v' message: '%v'}", errRes.Error.Type, errRes.Error.Message)
+// renderEvents prints tool activity, and optionally thinking, to stderr as it happens
+func renderEvents(events <-chan agents.Event, showThinking bool) {
+	for e := range events {
+		switch e.Type {
+		case agents.EVENT_THINKING:
+			if !showThinking {
+				continue
+			}
+			if e.Data == "" {
+				fmt.Fprintln(os.Stderr, "\n[thinking redacted]")
+			} else {
+				fmt.Fprintf(os.Stderr, "\n[thinking]\n%v\n", e.Data)
+			}
+		case agents.EVENT_TOOL_START:
+			fmt.Fprintf(os.Stderr, "\n[%v] %v\n", e.ToolName, e.Input)
+		case agents.EVENT_TOOL_OUTPUT:
+			fmt.Fprint(os.Stderr, e.Data)
+		case agents.EVENT_TOOL_END:
+			if e.IsError {
+				fmt.Fprintf(os.Stderr, "\n[%v error] %v\n", e.ToolName, e.Data)
+			} else {
+				fmt.Fprintln(os.Stderr)
+			}
+		}
 	}
-	return nil
 }
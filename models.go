@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/frozenkro/go-agent/agents"
+	"github.com/frozenkro/go-agent/clients"
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+// runModels refreshes the registry from the API and prints every model with
+// its limits and pricing
+func runModels() error {
+	ctx := context.Background()
+	client := clients.NewAnthropicClient()
+	if _, err := client.RefreshModels(ctx, anthropic.DefaultModels); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tCONTEXT\tMAX OUTPUT\tTHINKING\tINPUT $/MTOK\tOUTPUT $/MTOK")
+	for _, m := range anthropic.DefaultModels.Models() {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%.2f\t%.2f\n", m.Model, m.ContextWindow, m.MaxOutputTokens, m.Thinking, m.Pricing.Input, m.Pricing.Output)
+	}
+	return w.Flush()
+}
+
+// lookupModel returns the registry entry for model, refreshing the registry
+// from the API if the model is not known yet
+func lookupModel(ctx context.Context, client *clients.AnthropicClient, model anthropic.Model) (anthropic.ModelInfo, error) {
+	if info, err := anthropic.DefaultModels.Lookup(model); err == nil {
+		return info, nil
+	}
+	if _, err := client.RefreshModels(ctx, anthropic.DefaultModels); err != nil {
+		return anthropic.ModelInfo{}, err
+	}
+	return anthropic.DefaultModels.Lookup(model)
+}
+
+// maxTokensFor returns maxTokens, or the model's default when it is 0
+func maxTokensFor(info anthropic.ModelInfo, maxTokens int) int {
+	if maxTokens > 0 {
+		return maxTokens
+	}
+	return agents.DefaultMaxTokens(info)
+}
@@ -0,0 +1,66 @@
+package anthropic
+
+type BatchStatus string
+
+const (
+	BATCH_IN_PROGRESS BatchStatus = "in_progress"
+	BATCH_CANCELING   BatchStatus = "canceling"
+	BATCH_ENDED       BatchStatus = "ended"
+)
+
+type BatchResultType string
+
+const (
+	BATCH_SUCCEEDED BatchResultType = "succeeded"
+	BATCH_ERRORED   BatchResultType = "errored"
+	BATCH_CANCELED  BatchResultType = "canceled"
+	BATCH_EXPIRED   BatchResultType = "expired"
+)
+
+// BatchRequest is one request of a message batch. CustomId identifies its
+// result, which may arrive in any order.
+type BatchRequest struct {
+	CustomId string                   `json:"custom_id"`
+	Params   AnthropicMessagesRequest `json:"params"`
+}
+
+type BatchRequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+type MessageBatch struct {
+	Id                string             `json:"id"`
+	Type              string             `json:"type"`
+	ProcessingStatus  BatchStatus        `json:"processing_status"`
+	RequestCounts     BatchRequestCounts `json:"request_counts"`
+	CreatedAt         string             `json:"created_at"`
+	ExpiresAt         string             `json:"expires_at"`
+	EndedAt           string             `json:"ended_at,omitempty"`
+	CancelInitiatedAt string             `json:"cancel_initiated_at,omitempty"`
+	ArchivedAt        string             `json:"archived_at,omitempty"`
+	ResultsUrl        string             `json:"results_url,omitempty"`
+}
+
+type MessageBatchList struct {
+	Data    []MessageBatch `json:"data"`
+	FirstId string         `json:"first_id"`
+	LastId  string         `json:"last_id"`
+	HasMore bool           `json:"has_more"`
+}
+
+// BatchResult is one line of a batch's results. Message is set when the
+// request succeeded and Error when it errored.
+type BatchResult struct {
+	CustomId string            `json:"custom_id"`
+	Result   BatchResultDetail `json:"result"`
+}
+
+type BatchResultDetail struct {
+	Type    BatchResultType        `json:"type"`
+	Message *MessagesResponse      `json:"message,omitempty"`
+	Error   *MessagesErrorResponse `json:"error,omitempty"`
+}
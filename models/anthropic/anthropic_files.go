@@ -0,0 +1,35 @@
+package anthropic
+
+// File is the metadata of a file stored with the Files API
+type File struct {
+	Id           string    `json:"id"`
+	Type         string    `json:"type"`
+	Filename     string    `json:"filename"`
+	MimeType     MediaType `json:"mime_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	CreatedAt    string    `json:"created_at"`
+	Downloadable bool      `json:"downloadable"`
+}
+
+type FileList struct {
+	Data    []File `json:"data"`
+	FirstId string `json:"first_id"`
+	LastId  string `json:"last_id"`
+	HasMore bool   `json:"has_more"`
+}
+
+// Content returns a block referencing the file in a message: an image block
+// for images, a document block for PDFs and text, and otherwise a
+// container_upload block that makes it available to code execution
+func (f File) Content() Content {
+	switch {
+	case IsImageMediaType(f.MimeType):
+		return NewImageFileContent(f.Id)
+	case f.MimeType == MEDIA_PDF || f.MimeType == MEDIA_TEXT:
+		document := NewDocumentFileContent(f.Id)
+		document.Title = f.Filename
+		return document
+	default:
+		return NewContainerUploadContent(f.Id)
+	}
+}
@@ -0,0 +1,97 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type CitationsConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+type CitationType string
+
+const (
+	CHAR_LOCATION          CitationType = "char_location"
+	PAGE_LOCATION          CitationType = "page_location"
+	CONTENT_BLOCK_LOCATION CitationType = "content_block_location"
+	WEB_SEARCH_LOCATION    CitationType = "web_search_result_location"
+)
+
+// Citation links text in a response to the span of a document it is based
+// on. Which of the Start and End fields are set depends on Type: character
+// indices for plain text documents, page numbers for PDFs and block indices
+// for content documents. End values are exclusive. Web search citations
+// refer to a result by Url instead of a document.
+type Citation struct {
+	Type          CitationType `json:"type"`
+	CitedText     string       `json:"cited_text"`
+	DocumentIndex int          `json:"document_index"`
+	DocumentTitle string       `json:"document_title,omitempty"`
+
+	StartCharIndex  int `json:"start_char_index"`
+	EndCharIndex    int `json:"end_char_index"`
+	StartPageNumber int `json:"start_page_number"`
+	EndPageNumber   int `json:"end_page_number"`
+	StartBlockIndex int `json:"start_block_index"`
+	EndBlockIndex   int `json:"end_block_index"`
+
+	Url            string `json:"url,omitempty"`
+	Title          string `json:"title,omitempty"`
+	EncryptedIndex string `json:"encrypted_index,omitempty"`
+}
+
+// MarshalJSON writes only the location fields of the citation's type, as
+// citations are sent back to the API with the assistant messages they are in
+func (c Citation) MarshalJSON() ([]byte, error) {
+	fields := map[string]any{
+		"type":       c.Type,
+		"cited_text": c.CitedText,
+	}
+	if c.Type == WEB_SEARCH_LOCATION {
+		fields["url"] = c.Url
+		fields["title"] = c.Title
+		fields["encrypted_index"] = c.EncryptedIndex
+		return json.Marshal(fields)
+	}
+
+	fields["document_index"] = c.DocumentIndex
+	if c.DocumentTitle != "" {
+		fields["document_title"] = c.DocumentTitle
+	}
+	switch c.Type {
+	case CHAR_LOCATION:
+		fields["start_char_index"] = c.StartCharIndex
+		fields["end_char_index"] = c.EndCharIndex
+	case PAGE_LOCATION:
+		fields["start_page_number"] = c.StartPageNumber
+		fields["end_page_number"] = c.EndPageNumber
+	case CONTENT_BLOCK_LOCATION:
+		fields["start_block_index"] = c.StartBlockIndex
+		fields["end_block_index"] = c.EndBlockIndex
+	}
+	return json.Marshal(fields)
+}
+
+// Location describes the cited span, e.g. "pages 3-4"
+func (c Citation) Location() string {
+	switch c.Type {
+	case CHAR_LOCATION:
+		return fmt.Sprintf("characters %v-%v", c.StartCharIndex, c.EndCharIndex-1)
+	case PAGE_LOCATION:
+		return span("page", c.StartPageNumber, c.EndPageNumber-1)
+	case CONTENT_BLOCK_LOCATION:
+		return span("block", c.StartBlockIndex, c.EndBlockIndex-1)
+	case WEB_SEARCH_LOCATION:
+		return c.Url
+	default:
+		return string(c.Type)
+	}
+}
+
+func span(unit string, start int, end int) string {
+	if end <= start {
+		return fmt.Sprintf("%v %v", unit, start)
+	}
+	return fmt.Sprintf("%vs %v-%v", unit, start, end)
+}
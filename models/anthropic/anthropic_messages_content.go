@@ -1,8 +1,12 @@
 package anthropic
 
 import (
+	"encoding/base64"
 	"encoding/json"
-	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
 )
 
 type ContentTypes string
@@ -13,12 +17,36 @@ const (
 	REDACTED_THINKING          ContentTypes = "redacted_thinking"
 	TOOL_USE                   ContentTypes = "tool_use"
 	SERVER_TOOL_USE            ContentTypes = "server_tool_use"
-	TOOL_RESULT                ContentTypes = "tool_result" // This will only be sent back to the api, never come from the api
+	TOOL_RESULT                ContentTypes = "tool_result" // Only sent to the api, but decoded when reading requests back
 	WEB_SEARCH_TOOL_RESULT     ContentTypes = "web_search_tool_result"
+	WEB_FETCH_TOOL_RESULT      ContentTypes = "web_fetch_tool_result"
 	CODE_EXECUTION_TOOL_RESULT ContentTypes = "code_execution_tool_result"
 	MCP_TOOL_USE               ContentTypes = "mcp_tool_use"
 	MCP_TOOL_RESULT            ContentTypes = "mcp_tool_result"
 	CONTAINER_UPLOAD           ContentTypes = "container_upload"
+	IMAGE                      ContentTypes = "image"
+	DOCUMENT                   ContentTypes = "document"
+)
+
+type SourceType string
+
+const (
+	SOURCE_BASE64  SourceType = "base64"
+	SOURCE_URL     SourceType = "url"
+	SOURCE_TEXT    SourceType = "text"
+	SOURCE_CONTENT SourceType = "content"
+	SOURCE_FILE    SourceType = "file"
+)
+
+type MediaType string
+
+const (
+	MEDIA_JPEG MediaType = "image/jpeg"
+	MEDIA_PNG  MediaType = "image/png"
+	MEDIA_GIF  MediaType = "image/gif"
+	MEDIA_WEBP MediaType = "image/webp"
+	MEDIA_PDF  MediaType = "application/pdf"
+	MEDIA_TEXT MediaType = "text/plain"
 )
 
 // Content interface that all content types implement
@@ -35,10 +63,34 @@ func (b BaseContent) GetType() ContentTypes {
 	return b.Type
 }
 
+// UnknownContent is a block of a type this package does not know yet, e.g.
+// one added to the API since. Its JSON is kept so it is sent back unchanged.
+type UnknownContent struct {
+	BaseContent
+	Raw json.RawMessage `json:"-"`
+}
+
+func (c *UnknownContent) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.BaseContent); err != nil {
+		return err
+	}
+	c.Raw = append(json.RawMessage{}, data...)
+	return nil
+}
+
+func (c UnknownContent) MarshalJSON() ([]byte, error) {
+	if c.Raw == nil {
+		return json.Marshal(c.BaseContent)
+	}
+	return c.Raw, nil
+}
+
 // Specific content type structs
 type TextContent struct {
 	BaseContent
-	Text string `json:"text"`
+	Text         string        `json:"text"`
+	Citations    []Citation    `json:"citations,omitempty"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
 }
 
 type ThinkingContent struct {
@@ -52,6 +104,161 @@ type RedactedThinkingContent struct {
 	Data string `json:"data"`
 }
 
+func NewTextContent(text string) *TextContent {
+	return &TextContent{
+		BaseContent: BaseContent{Type: TEXT},
+		Text:        text,
+	}
+}
+
+// Source of an image or document. Data holds base64 encoded bytes for
+// SOURCE_BASE64 and the text itself for SOURCE_TEXT. FileId refers to a file
+// uploaded with the Files API for SOURCE_FILE. Content holds the text
+// blocks of a SOURCE_CONTENT document, each of which can be cited.
+type Source struct {
+	Type      SourceType `json:"type"`
+	MediaType MediaType  `json:"media_type,omitempty"`
+	Data      string     `json:"data,omitempty"`
+	Url       string     `json:"url,omitempty"`
+	Content   []Content  `json:"content,omitempty"`
+	FileId    string     `json:"file_id,omitempty"`
+}
+
+func (s *Source) UnmarshalJSON(data []byte) error {
+	type Alias Source
+	aux := &struct {
+		Content json.RawMessage `json:"content"`
+		*Alias
+	}{
+		Alias: (*Alias)(s),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.Content) == 0 {
+		return nil
+	}
+
+	contents, err := UnmarshalContents(aux.Content)
+	if err != nil {
+		return err
+	}
+	s.Content = contents
+	return nil
+}
+
+type ImageContent struct {
+	BaseContent
+	Source       Source        `json:"source"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+type DocumentContent struct {
+	BaseContent
+	Source       Source           `json:"source"`
+	Title        string           `json:"title,omitempty"`
+	Context      string           `json:"context,omitempty"`
+	Citations    *CitationsConfig `json:"citations,omitempty"`
+	CacheControl *CacheControl    `json:"cache_control,omitempty"`
+}
+
+func NewImageContent(mediaType MediaType, data []byte) *ImageContent {
+	return &ImageContent{
+		BaseContent: BaseContent{Type: IMAGE},
+		Source:      Source{Type: SOURCE_BASE64, MediaType: mediaType, Data: base64.StdEncoding.EncodeToString(data)},
+	}
+}
+
+func NewImageUrlContent(url string) *ImageContent {
+	return &ImageContent{
+		BaseContent: BaseContent{Type: IMAGE},
+		Source:      Source{Type: SOURCE_URL, Url: url},
+	}
+}
+
+func NewPdfDocumentContent(data []byte) *DocumentContent {
+	return &DocumentContent{
+		BaseContent: BaseContent{Type: DOCUMENT},
+		Source:      Source{Type: SOURCE_BASE64, MediaType: MEDIA_PDF, Data: base64.StdEncoding.EncodeToString(data)},
+	}
+}
+
+func NewTextDocumentContent(text string) *DocumentContent {
+	return &DocumentContent{
+		BaseContent: BaseContent{Type: DOCUMENT},
+		Source:      Source{Type: SOURCE_TEXT, MediaType: MEDIA_TEXT, Data: text},
+	}
+}
+
+// NewBlocksDocumentContent creates a document from text blocks. Citations
+// into it refer to whole blocks rather than character ranges.
+func NewBlocksDocumentContent(blocks ...string) *DocumentContent {
+	content := make([]Content, len(blocks))
+	for i, block := range blocks {
+		content[i] = NewTextContent(block)
+	}
+	return &DocumentContent{
+		BaseContent: BaseContent{Type: DOCUMENT},
+		Source:      Source{Type: SOURCE_CONTENT, Content: content},
+	}
+}
+
+// NewDocumentUrlContent references a PDF by url
+func NewDocumentUrlContent(url string) *DocumentContent {
+	return &DocumentContent{
+		BaseContent: BaseContent{Type: DOCUMENT},
+		Source:      Source{Type: SOURCE_URL, Url: url},
+	}
+}
+
+// NewImageFileContent references an image uploaded with the Files API
+func NewImageFileContent(fileId string) *ImageContent {
+	return &ImageContent{
+		BaseContent: BaseContent{Type: IMAGE},
+		Source:      Source{Type: SOURCE_FILE, FileId: fileId},
+	}
+}
+
+// NewDocumentFileContent references a PDF or text file uploaded with the Files API
+func NewDocumentFileContent(fileId string) *DocumentContent {
+	return &DocumentContent{
+		BaseContent: BaseContent{Type: DOCUMENT},
+		Source:      Source{Type: SOURCE_FILE, FileId: fileId},
+	}
+}
+
+// NewContainerUploadContent makes an uploaded file available to code execution
+func NewContainerUploadContent(fileId string) *ContainerUploadContent {
+	return &ContainerUploadContent{
+		BaseContent: BaseContent{Type: CONTAINER_UPLOAD},
+		FileId:      fileId,
+	}
+}
+
+// IsImageMediaType reports whether the API accepts mediaType in image blocks
+func IsImageMediaType(mediaType MediaType) bool {
+	switch mediaType {
+	case MEDIA_JPEG, MEDIA_PNG, MEDIA_GIF, MEDIA_WEBP:
+		return true
+	}
+	return false
+}
+
+// DetectMediaType determines the media type of a file from its extension,
+// falling back to sniffing data
+func DetectMediaType(path string, data []byte) MediaType {
+	mediaType := mime.TypeByExtension(filepath.Ext(path))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	if strings.HasPrefix(mediaType, "text/") {
+		return MEDIA_TEXT
+	}
+	return MediaType(mediaType)
+}
+
 type ToolUseContent struct {
 	BaseContent
 	Id    string   `json:"id"`
@@ -59,23 +266,190 @@ type ToolUseContent struct {
 	Input any      `json:"input"`
 }
 
+// ToolResultContent answers a tool use with text and image blocks
 type ToolResultContent struct {
 	BaseContent
-	ToolUseId string `json:"tool_use_id"`
-	Content   string `json:"content"`
+	ToolUseId string    `json:"tool_use_id"`
+	Content   []Content `json:"content,omitempty"`
+	IsError   bool      `json:"is_error,omitempty"`
 }
 
+func (t *ToolResultContent) UnmarshalJSON(data []byte) error {
+	type Alias ToolResultContent
+	aux := &struct {
+		Content json.RawMessage `json:"content"`
+		*Alias
+	}{
+		Alias: (*Alias)(t),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.Content) == 0 {
+		return nil
+	}
+
+	// Content may be given as a plain string
+	var text string
+	if err := json.Unmarshal(aux.Content, &text); err == nil {
+		if text != "" {
+			t.Content = []Content{NewTextContent(text)}
+		}
+		return nil
+	}
+
+	contents, err := UnmarshalContents(aux.Content)
+	if err != nil {
+		return err
+	}
+	t.Content = contents
+	return nil
+}
+
+// Text joins the text blocks of the result
+func (t ToolResultContent) Text() string {
+	texts := []string{}
+	for _, c := range t.Content {
+		if text, ok := c.(*TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ServerToolUseContent is a call to a tool run by the API, such as web search.
+// It is answered by the API, never by a local tool.
+type ServerToolUseContent struct {
+	BaseContent
+	Id    string   `json:"id"`
+	Name  ToolName `json:"name"`
+	Input any      `json:"input"`
+}
+
+// WebSearchToolResultContent holds either the results of a web search or,
+// when the search failed, Error
 type WebSearchToolResultContent struct {
 	BaseContent
-	ToolUseId string                            `json:"tool_use_id"`
-	Content   WebSearchToolResultContentContent `json:"content"`
+	ToolUseId string                             `json:"tool_use_id"`
+	Results   []WebSearchResult                  `json:"-"`
+	Error     *WebSearchToolResultContentContent `json:"-"`
 }
 
+type WebSearchResult struct {
+	Type             string `json:"type"`
+	Url              string `json:"url"`
+	Title            string `json:"title"`
+	EncryptedContent string `json:"encrypted_content"`
+	PageAge          string `json:"page_age,omitempty"`
+}
+
+// WebSearchToolResultContentContent is the error of a failed web search
 type WebSearchToolResultContentContent struct {
 	BaseContent
 	ErrorCode string `json:"error_code"`
 }
 
+func (w *WebSearchToolResultContent) UnmarshalJSON(data []byte) error {
+	type Alias WebSearchToolResultContent
+	aux := &struct {
+		Content json.RawMessage `json:"content"`
+		*Alias
+	}{
+		Alias: (*Alias)(w),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.Content) > 0 && aux.Content[0] == '[' {
+		return json.Unmarshal(aux.Content, &w.Results)
+	}
+	w.Error = &WebSearchToolResultContentContent{}
+	return json.Unmarshal(aux.Content, w.Error)
+}
+
+func (w WebSearchToolResultContent) MarshalJSON() ([]byte, error) {
+	type Alias WebSearchToolResultContent
+	var content any = w.Results
+	if w.Error != nil {
+		content = w.Error
+	} else if w.Results == nil {
+		content = []WebSearchResult{}
+	}
+	return json.Marshal(struct {
+		Alias
+		Content any `json:"content"`
+	}{Alias(w), content})
+}
+
+// WebFetchToolResultContent holds either the fetched document or, when the
+// fetch failed, Error
+type WebFetchToolResultContent struct {
+	BaseContent
+	ToolUseId string                        `json:"tool_use_id"`
+	Result    *WebFetchResult               `json:"-"`
+	Error     *WebFetchToolResultContentErr `json:"-"`
+}
+
+type WebFetchResult struct {
+	Type        string          `json:"type"`
+	Url         string          `json:"url"`
+	Content     DocumentContent `json:"content"`
+	RetrievedAt string          `json:"retrieved_at,omitempty"`
+}
+
+type WebFetchToolResultContentErr struct {
+	BaseContent
+	ErrorCode string `json:"error_code"`
+}
+
+func (w *WebFetchToolResultContent) UnmarshalJSON(data []byte) error {
+	type Alias WebFetchToolResultContent
+	aux := &struct {
+		Content json.RawMessage `json:"content"`
+		*Alias
+	}{
+		Alias: (*Alias)(w),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	var base BaseContent
+	if err := json.Unmarshal(aux.Content, &base); err != nil {
+		return err
+	}
+	if base.Type == "web_fetch_result" {
+		w.Result = &WebFetchResult{}
+		return json.Unmarshal(aux.Content, w.Result)
+	}
+	w.Error = &WebFetchToolResultContentErr{}
+	return json.Unmarshal(aux.Content, w.Error)
+}
+
+func (w WebFetchToolResultContent) MarshalJSON() ([]byte, error) {
+	type Alias WebFetchToolResultContent
+	var content any = w.Result
+	if w.Error != nil {
+		content = w.Error
+	}
+	return json.Marshal(struct {
+		Alias
+		Content any `json:"content"`
+	}{Alias(w), content})
+}
+
+const (
+	CODE_EXECUTION_RESULT       ContentTypes = "code_execution_result"
+	CODE_EXECUTION_RESULT_ERROR ContentTypes = "code_execution_tool_result_error"
+	CODE_EXECUTION_OUTPUT       ContentTypes = "code_execution_output"
+)
+
+// CodeExecutionToolResultContent holds the outcome of server-side code
+// execution. Content is a *CodeExecutionToolResultContentContent when the
+// code ran, or a *CodeExecutionToolResultContentErr when it could not be run.
 type CodeExecutionToolResultContent struct {
 	BaseContent
 	Content   Content `json:"-"`
@@ -84,10 +458,10 @@ type CodeExecutionToolResultContent struct {
 
 type CodeExecutionToolResultContentContent struct {
 	BaseContent
-	Content    CodeExecutionToolResultContentContentContent `json:"content"`
-	ReturnCode int                                          `json:"return_code"`
-	StdErr     string                                       `json:"stderr"`
-	StdOut     string                                       `json:"stdout"`
+	Content    []CodeExecutionToolResultContentContentContent `json:"content"`
+	ReturnCode int                                            `json:"return_code"`
+	StdErr     string                                         `json:"stderr"`
+	StdOut     string                                         `json:"stdout"`
 }
 
 type CodeExecutionToolResultContentErr struct {
@@ -100,6 +474,61 @@ type CodeExecutionToolResultContentContentContent struct {
 	FileId string `json:"file_id"`
 }
 
+func (c *CodeExecutionToolResultContent) UnmarshalJSON(data []byte) error {
+	type Alias CodeExecutionToolResultContent
+	aux := &struct {
+		Content json.RawMessage `json:"content"`
+		*Alias
+	}{
+		Alias: (*Alias)(c),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	var base BaseContent
+	if err := json.Unmarshal(aux.Content, &base); err != nil {
+		return err
+	}
+
+	switch base.Type {
+	case CODE_EXECUTION_RESULT:
+		c.Content = &CodeExecutionToolResultContentContent{}
+	case CODE_EXECUTION_RESULT_ERROR:
+		c.Content = &CodeExecutionToolResultContentErr{}
+	default:
+		c.Content = &UnknownContent{}
+	}
+	return json.Unmarshal(aux.Content, c.Content)
+}
+
+func (c CodeExecutionToolResultContent) MarshalJSON() ([]byte, error) {
+	type Alias CodeExecutionToolResultContent
+	return json.Marshal(struct {
+		Alias
+		Content Content `json:"content"`
+	}{Alias(c), c.Content})
+}
+
+// Result returns the output of the execution, or false if it failed to run
+func (c CodeExecutionToolResultContent) Result() (*CodeExecutionToolResultContentContent, bool) {
+	result, ok := c.Content.(*CodeExecutionToolResultContentContent)
+	return result, ok
+}
+
+// FileIds returns the ids of files the code wrote, which can be downloaded
+// with the Files API
+func (c CodeExecutionToolResultContent) FileIds() []string {
+	ids := []string{}
+	if result, ok := c.Result(); ok {
+		for _, output := range result.Content {
+			ids = append(ids, output.FileId)
+		}
+	}
+	return ids
+}
+
 type MCPToolUseContent struct {
 	BaseContent
 	Id         string `json:"id"`
@@ -144,9 +573,13 @@ func UnmarshalContents(data []byte) ([]Content, error) {
 		case TOOL_USE:
 			content = &ToolUseContent{}
 		case SERVER_TOOL_USE:
-			content = &ToolUseContent{}
+			content = &ServerToolUseContent{}
+		case TOOL_RESULT:
+			content = &ToolResultContent{}
 		case WEB_SEARCH_TOOL_RESULT:
 			content = &WebSearchToolResultContent{}
+		case WEB_FETCH_TOOL_RESULT:
+			content = &WebFetchToolResultContent{}
 		case CODE_EXECUTION_TOOL_RESULT:
 			content = &CodeExecutionToolResultContent{}
 		case MCP_TOOL_USE:
@@ -155,8 +588,12 @@ func UnmarshalContents(data []byte) ([]Content, error) {
 			content = &MCPToolResultContent{}
 		case CONTAINER_UPLOAD:
 			content = &ContainerUploadContent{}
+		case IMAGE:
+			content = &ImageContent{}
+		case DOCUMENT:
+			content = &DocumentContent{}
 		default:
-			return nil, fmt.Errorf("unknown content type: %s", base.Type)
+			content = &UnknownContent{}
 		}
 
 		if err := json.Unmarshal(raw, content); err != nil {
@@ -0,0 +1,81 @@
+package anthropic_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+const futureBlock = `{"type":"future_block","data":{"nested":[1,2,3]},"signature":"abc"}`
+
+func jsonEqual(t *testing.T, want string, got []byte) {
+	t.Helper()
+	var w, g any
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(w, g) {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestUnknownContentRoundTrips(t *testing.T) {
+	data := `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","stop_reason":"end_turn",
+		"content":[{"type":"text","text":"hi"},` + futureBlock + `]}`
+
+	var response anthropic.MessagesResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Content) != 2 {
+		t.Fatalf("Expected 2 blocks, got %v", len(response.Content))
+	}
+	unknown, ok := response.Content[1].(*anthropic.UnknownContent)
+	if !ok {
+		t.Fatalf("Expected *UnknownContent, got %T", response.Content[1])
+	}
+	if unknown.GetType() != "future_block" {
+		t.Errorf("Expected type future_block, got %v", unknown.GetType())
+	}
+
+	// Sent back in the next request as it was received
+	message := anthropic.Message{Role: anthropic.ASSISTANT, Content: response.Content}
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Content []json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	jsonEqual(t, futureBlock, decoded.Content[1])
+}
+
+func TestUnknownCodeExecutionResult(t *testing.T) {
+	data := `[{"type":"code_execution_tool_result","tool_use_id":"srvtoolu_1","content":` + futureBlock + `}]`
+
+	contents, err := anthropic.UnmarshalContents([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, ok := contents[0].(*anthropic.CodeExecutionToolResultContent)
+	if !ok {
+		t.Fatalf("Expected *CodeExecutionToolResultContent, got %T", contents[0])
+	}
+	if _, ok := result.Result(); ok {
+		t.Errorf("Expected no result for an unknown result type")
+	}
+
+	encoded, err := json.Marshal([]anthropic.Content{result})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonEqual(t, data, encoded)
+}
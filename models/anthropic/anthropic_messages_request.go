@@ -1,10 +1,46 @@
 package anthropic
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 type Message struct {
 	Role    Role      `json:"role"`
 	Content []Content `json:"content"`
 }
 
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type Alias Message
+	aux := &struct {
+		Content json.RawMessage `json:"content"`
+		*Alias
+	}{
+		Alias: (*Alias)(m),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	// Content may be given as a plain string
+	var text string
+	if err := json.Unmarshal(aux.Content, &text); err == nil {
+		m.Content = []Content{NewTextContent(text)}
+		return nil
+	}
+
+	contents, err := UnmarshalContents(aux.Content)
+	if err != nil {
+		return err
+	}
+	m.Content = contents
+
+	return nil
+}
+
 type Role string
 
 const (
@@ -29,16 +65,43 @@ type Metadata struct {
 	UserId string `json:"user_id"`
 }
 
+type ServiceTier string
+
+const (
+	SERVICE_TIER_AUTO          ServiceTier = "auto"
+	SERVICE_TIER_STANDARD_ONLY ServiceTier = "standard_only"
+)
+
 type ThinkingData struct {
 	BudgetTokens int    `json:"budget_tokens"`
 	Type         string `json:"type"`
 }
 
+const (
+	THINKING_ENABLED    string = "enabled"
+	MIN_THINKING_BUDGET int    = 1024
+)
+
+// Beta features enabled with the anthropic-beta header
+const (
+	BETA_INTERLEAVED_THINKING string = "interleaved-thinking-2025-05-14"
+	BETA_WEB_FETCH            string = "web-fetch-2025-09-10"
+	BETA_CODE_EXECUTION       string = "code-execution-2025-05-22"
+	BETA_FILES_API            string = "files-api-2025-04-14"
+)
+
 type ToolName string
 
 const (
-	BASH        ToolName = "bash"
-	TEXT_EDITOR ToolName = "str_replace_based_edit_tool"
+	BASH         ToolName = "bash"
+	TEXT_EDITOR  ToolName = "str_replace_based_edit_tool"
+	BASH_SESSION ToolName = "bash_session"
+	BASH_JOB     ToolName = "bash_job"
+
+	// Server tools, run by the API
+	WEB_SEARCH     ToolName = "web_search"
+	WEB_FETCH      ToolName = "web_fetch"
+	CODE_EXECUTION ToolName = "code_execution"
 )
 
 type AnthropicToolSpec interface {
@@ -51,6 +114,28 @@ type BaseTool struct {
 	Type string   `json:"type"`
 }
 
+// RawTool is a tool decoded from JSON, e.g. in batch params, which could be
+// any kind of tool. It is sent as it was received.
+type RawTool struct {
+	BaseTool
+	Raw json.RawMessage `json:"-"`
+}
+
+func (t *RawTool) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &t.BaseTool); err != nil {
+		return err
+	}
+	t.Raw = append(json.RawMessage{}, data...)
+	return nil
+}
+
+func (t RawTool) MarshalJSON() ([]byte, error) {
+	if t.Raw == nil {
+		return json.Marshal(t.BaseTool)
+	}
+	return t.Raw, nil
+}
+
 func (t BaseTool) GetType() string {
 	return t.Type
 }
@@ -82,6 +167,191 @@ func NewTextEditorTool() TextEditorTool {
 	}
 }
 
+// CustomTool is a client tool described by a JSON Schema for its input
+type CustomTool struct {
+	BaseTool
+	Description  string         `json:"description,omitempty"`
+	InputSchema  map[string]any `json:"input_schema"`
+	CacheControl *CacheControl  `json:"cache_control,omitempty"`
+}
+
+func NewCustomTool(name ToolName, description string, inputSchema map[string]any) CustomTool {
+	return CustomTool{
+		BaseTool:    BaseTool{Type: "custom", Name: name},
+		Description: description,
+		InputSchema: inputSchema,
+	}
+}
+
+func NewBashSessionTool() CustomTool {
+	return NewCustomTool(
+		BASH_SESSION,
+		"Run a command in a named, persistent bash session. Sessions are created on first use and keep their "+
+			"working directory and environment between calls, so separate sessions can be used side by side. "+
+			"The session named \"default\" is shared with the bash tool.",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"session": map[string]any{"type": "string", "description": "Name of the session, e.g. \"server\" or \"tests\""},
+				"command": map[string]any{"type": "string", "description": "The command to run"},
+				"restart": map[string]any{"type": "boolean", "description": "Restart the session before running the command"},
+			},
+			"required": []string{"session"},
+		},
+	)
+}
+
+func NewBashJobTool() CustomTool {
+	return NewCustomTool(
+		BASH_JOB,
+		"Manage long-running background commands such as dev servers or watchers. "+
+			"\"start\" runs a command without waiting for it to finish, \"poll\" returns output produced since the last poll, "+
+			"\"send\" writes a line of input to the job, \"kill\" stops it and \"list\" shows all jobs.",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"action":  map[string]any{"type": "string", "enum": []string{"start", "poll", "send", "kill", "list"}},
+				"job":     map[string]any{"type": "string", "description": "Name of the job. Generated on start if omitted"},
+				"command": map[string]any{"type": "string", "description": "Command to run, for start"},
+				"input":   map[string]any{"type": "string", "description": "Line of input to send, for send"},
+			},
+			"required": []string{"action"},
+		},
+	)
+}
+
+// BetaTool is implemented by tool specs that need a beta feature enabled
+type BetaTool interface {
+	Beta() string
+}
+
+// ValidatingTool is implemented by tool specs whose settings can be invalid
+type ValidatingTool interface {
+	Validate() error
+}
+
+func validateDomains(name ToolName, maxUses int, allowed []string, blocked []string) error {
+	if maxUses < 0 {
+		return fmt.Errorf("%v max_uses cannot be negative", name)
+	}
+	if len(allowed) > 0 && len(blocked) > 0 {
+		return fmt.Errorf("%v can have allowed_domains or blocked_domains, not both", name)
+	}
+	return nil
+}
+
+// UserLocation localizes web search results
+type UserLocation struct {
+	Type     string `json:"type"`
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func NewUserLocation(city string, region string, country string, timezone string) *UserLocation {
+	return &UserLocation{Type: "approximate", City: city, Region: region, Country: country, Timezone: timezone}
+}
+
+// WebSearchTool lets the model search the web. Only one of AllowedDomains
+// and BlockedDomains may be set.
+type WebSearchTool struct {
+	BaseTool
+	MaxUses        int           `json:"max_uses,omitempty"`
+	AllowedDomains []string      `json:"allowed_domains,omitempty"`
+	BlockedDomains []string      `json:"blocked_domains,omitempty"`
+	UserLocation   *UserLocation `json:"user_location,omitempty"`
+	CacheControl   *CacheControl `json:"cache_control,omitempty"`
+}
+
+func (t WebSearchTool) Validate() error {
+	return validateDomains(t.Name, t.MaxUses, t.AllowedDomains, t.BlockedDomains)
+}
+
+func NewWebSearchTool() WebSearchTool {
+	return WebSearchTool{
+		BaseTool: BaseTool{Type: "web_search_20250305", Name: WEB_SEARCH},
+	}
+}
+
+// WebFetchTool lets the model retrieve the content of web pages and PDFs.
+// Only one of AllowedDomains and BlockedDomains may be set.
+type WebFetchTool struct {
+	BaseTool
+	MaxUses          int              `json:"max_uses,omitempty"`
+	AllowedDomains   []string         `json:"allowed_domains,omitempty"`
+	BlockedDomains   []string         `json:"blocked_domains,omitempty"`
+	Citations        *CitationsConfig `json:"citations,omitempty"`
+	MaxContentTokens int              `json:"max_content_tokens,omitempty"`
+	CacheControl     *CacheControl    `json:"cache_control,omitempty"`
+}
+
+func NewWebFetchTool() WebFetchTool {
+	return WebFetchTool{
+		BaseTool: BaseTool{Type: "web_fetch_20250910", Name: WEB_FETCH},
+	}
+}
+
+func (t WebFetchTool) Validate() error {
+	if t.MaxContentTokens < 0 {
+		return fmt.Errorf("%v max_content_tokens cannot be negative", t.Name)
+	}
+	return validateDomains(t.Name, t.MaxUses, t.AllowedDomains, t.BlockedDomains)
+}
+
+func (t WebFetchTool) Beta() string {
+	return BETA_WEB_FETCH
+}
+
+// CodeExecutionTool lets the model run Python in a sandboxed container
+type CodeExecutionTool struct {
+	BaseTool
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+func NewCodeExecutionTool() CodeExecutionTool {
+	return CodeExecutionTool{
+		BaseTool: BaseTool{Type: "code_execution_20250522", Name: CODE_EXECUTION},
+	}
+}
+
+func (t CodeExecutionTool) Beta() string {
+	return BETA_CODE_EXECUTION
+}
+
+type ToolChoiceType string
+
+const (
+	TOOL_CHOICE_AUTO ToolChoiceType = "auto"
+	TOOL_CHOICE_ANY  ToolChoiceType = "any"
+	TOOL_CHOICE_TOOL ToolChoiceType = "tool"
+	TOOL_CHOICE_NONE ToolChoiceType = "none"
+)
+
+// ToolChoice controls whether the model must use a tool. Name is only set
+// for TOOL_CHOICE_TOOL.
+type ToolChoice struct {
+	Type                   ToolChoiceType `json:"type"`
+	Name                   ToolName       `json:"name,omitempty"`
+	DisableParallelToolUse bool           `json:"disable_parallel_tool_use,omitempty"`
+}
+
+func NewToolChoice(choiceType ToolChoiceType) ToolChoice {
+	return ToolChoice{Type: choiceType}
+}
+
+// NewToolChoiceTool forces the model to use the tool named name
+func NewToolChoiceTool(name ToolName) ToolChoice {
+	return ToolChoice{Type: TOOL_CHOICE_TOOL, Name: name}
+}
+
+// Forces reports whether the choice requires the model to use a tool
+func (c ToolChoice) Forces() bool {
+	return c.Type == TOOL_CHOICE_ANY || c.Type == TOOL_CHOICE_TOOL
+}
+
+const CACHE_EPHEMERAL string = "ephemeral"
+
 type CacheTTL string
 
 const (
@@ -94,6 +364,66 @@ type CacheControl struct {
 	TTL  CacheTTL `json:"ttl,omitempty"`
 }
 
+func NewCacheControl(ttl CacheTTL) *CacheControl {
+	return &CacheControl{Type: CACHE_EPHEMERAL, TTL: ttl}
+}
+
+// SystemPrompt is sent as text blocks so that it can be cached. It can be
+// decoded from a plain string as well.
+type SystemPrompt []TextContent
+
+func NewSystemPrompt(texts ...string) SystemPrompt {
+	prompt := SystemPrompt{}
+	for _, text := range texts {
+		prompt = append(prompt, *NewTextContent(text))
+	}
+	return prompt
+}
+
+// Cached returns a copy of the prompt with a cache breakpoint on its last
+// block, which caches the tools and the whole system prompt
+func (p SystemPrompt) Cached(ttl CacheTTL) SystemPrompt {
+	cached := append(SystemPrompt{}, p...)
+	if len(cached) > 0 {
+		cached[len(cached)-1].CacheControl = NewCacheControl(ttl)
+	}
+	return cached
+}
+
+// Text joins the blocks of the prompt with blank lines
+func (p SystemPrompt) Text() string {
+	texts := make([]string, len(p))
+	for i, block := range p {
+		texts[i] = block.Text
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// UnmarshalJSON decodes a string or a list of blocks. null and an empty
+// string decode to no prompt rather than an empty block.
+func (p *SystemPrompt) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		*p = nil
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*p = nil
+		if text != "" {
+			*p = NewSystemPrompt(text)
+		}
+		return nil
+	}
+
+	var blocks []TextContent
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return err
+	}
+	*p = blocks
+	return nil
+}
+
 type AnthropicMessagesRequest struct {
 	Model         Model               `json:"model"`
 	Messages      []Message           `json:"messages"`
@@ -101,20 +431,37 @@ type AnthropicMessagesRequest struct {
 	Container     string              `json:"container,omitempty"`
 	MCPServers    []MCPServer         `json:"mcp_servers,omitempty"`
 	Metadata      *Metadata           `json:"metadata,omitempty"`
-	ServiceTier   string              `json:"service_tier,omitempty"`
+	ServiceTier   ServiceTier         `json:"service_tier,omitempty"`
 	StopSequences []string            `json:"stop_sequences,omitempty"`
 	Stream        bool                `json:"stream,omitempty"`
-	System        string              `json:"system,omitempty"` //System prompt
-	Temperature   float32             `json:"temperature,omitempty"`
+	System        SystemPrompt        `json:"system,omitempty"`
+	Temperature   *float64            `json:"temperature,omitempty"`
 	Thinking      *ThinkingData       `json:"thinking,omitempty"`
-	ToolChoice    any                 `json:"tool_choice,omitempty"`
+	ToolChoice    *ToolChoice         `json:"tool_choice,omitempty"`
 	Tools         []AnthropicToolSpec `json:"tools,omitempty"`
 	TopK          int                 `json:"top_k,omitempty"`
-	TopP          int                 `json:"top_p,omitempty"`
+	TopP          *float64            `json:"top_p,omitempty"`
+	// Betas are sent in the anthropic-beta header rather than the body
+	Betas []string `json:"-"`
 }
 
-type Model string
+// UnmarshalJSON decodes tools as RawTool, as the kind of each tool is only
+// known from its fields
+func (r *AnthropicMessagesRequest) UnmarshalJSON(data []byte) error {
+	type Alias AnthropicMessagesRequest
+	aux := &struct {
+		Tools []*RawTool `json:"tools"`
+		*Alias
+	}{
+		Alias: (*Alias)(r),
+	}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
 
-const (
-	SONNET_4 Model = "claude-sonnet-4-20250514"
-)
+	r.Tools = nil
+	for _, tool := range aux.Tools {
+		r.Tools = append(r.Tools, tool)
+	}
+	return nil
+}
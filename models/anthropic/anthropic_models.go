@@ -0,0 +1,231 @@
+package anthropic
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+type Model string
+
+const (
+	OPUS_4_1   Model = "claude-opus-4-1-20250805"
+	OPUS_4     Model = "claude-opus-4-20250514"
+	SONNET_4_5 Model = "claude-sonnet-4-5-20250929"
+	SONNET_4   Model = "claude-sonnet-4-20250514"
+	SONNET_3_7 Model = "claude-3-7-sonnet-20250219"
+	HAIKU_4_5  Model = "claude-haiku-4-5-20251001"
+	HAIKU_3_5  Model = "claude-3-5-haiku-20241022"
+)
+
+// Pricing in US dollars per million tokens
+type Pricing struct {
+	Input      float64
+	Output     float64
+	CacheWrite float64 // 5 minute cache writes
+	CacheRead  float64
+}
+
+// Cost returns the price of a response's usage in US dollars
+func (p Pricing) Cost(usage MessagesUsage) float64 {
+	return (float64(usage.InputTokens)*p.Input +
+		float64(usage.OutputTokens)*p.Output +
+		float64(usage.CacheCreationInputTokens)*p.CacheWrite +
+		float64(usage.CacheReadInputTokens)*p.CacheRead) / 1e6
+}
+
+// ModelInfo describes the capabilities and limits of a model. Tools maps
+// the Anthropic-defined tools the model supports to their tool type.
+type ModelInfo struct {
+	Model       Model
+	DisplayName string
+	// Aliases, such as claude-sonnet-4-5, resolve to the newest snapshot
+	Aliases             []Model
+	ContextWindow       int
+	MaxOutputTokens     int
+	Thinking            bool
+	InterleavedThinking bool
+	Tools               map[ToolName]string
+	Pricing             Pricing
+}
+
+// SupportsToolType reports whether the model accepts a tool spec of toolType.
+// Custom tools are supported by every model.
+func (m ModelInfo) SupportsToolType(toolType string) bool {
+	if toolType == "custom" || toolType == "" {
+		return true
+	}
+	for _, t := range m.Tools {
+		if t == toolType {
+			return true
+		}
+	}
+	return false
+}
+
+var claude4Tools = map[ToolName]string{
+	BASH:           "bash_20250124",
+	TEXT_EDITOR:    "text_editor_20250728",
+	WEB_SEARCH:     "web_search_20250305",
+	WEB_FETCH:      "web_fetch_20250910",
+	CODE_EXECUTION: "code_execution_20250522",
+}
+
+var KNOWN_MODELS = []ModelInfo{
+	{
+		Model: OPUS_4_1, DisplayName: "Claude Opus 4.1", Aliases: []Model{"claude-opus-4-1"},
+		ContextWindow: 200000, MaxOutputTokens: 32000, Thinking: true, InterleavedThinking: true,
+		Tools:   claude4Tools,
+		Pricing: Pricing{Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.5},
+	},
+	{
+		Model: OPUS_4, DisplayName: "Claude Opus 4", Aliases: []Model{"claude-opus-4-0"},
+		ContextWindow: 200000, MaxOutputTokens: 32000, Thinking: true, InterleavedThinking: true,
+		Tools:   claude4Tools,
+		Pricing: Pricing{Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.5},
+	},
+	{
+		Model: SONNET_4_5, DisplayName: "Claude Sonnet 4.5", Aliases: []Model{"claude-sonnet-4-5"},
+		ContextWindow: 200000, MaxOutputTokens: 64000, Thinking: true, InterleavedThinking: true,
+		Tools:   claude4Tools,
+		Pricing: Pricing{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+	},
+	{
+		Model: SONNET_4, DisplayName: "Claude Sonnet 4", Aliases: []Model{"claude-sonnet-4-0"},
+		ContextWindow: 200000, MaxOutputTokens: 64000, Thinking: true, InterleavedThinking: true,
+		Tools:   claude4Tools,
+		Pricing: Pricing{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+	},
+	{
+		Model: HAIKU_4_5, DisplayName: "Claude Haiku 4.5", Aliases: []Model{"claude-haiku-4-5"},
+		ContextWindow: 200000, MaxOutputTokens: 64000, Thinking: true, InterleavedThinking: true,
+		Tools:   claude4Tools,
+		Pricing: Pricing{Input: 1, Output: 5, CacheWrite: 1.25, CacheRead: 0.1},
+	},
+	{
+		Model: SONNET_3_7, DisplayName: "Claude Sonnet 3.7", Aliases: []Model{"claude-3-7-sonnet-latest"},
+		ContextWindow: 200000, MaxOutputTokens: 64000, Thinking: true,
+		Tools: map[ToolName]string{
+			BASH:                 "bash_20250124",
+			"str_replace_editor": "text_editor_20250124",
+			WEB_SEARCH:           "web_search_20250305",
+			CODE_EXECUTION:       "code_execution_20250522",
+		},
+		Pricing: Pricing{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+	},
+	{
+		Model: HAIKU_3_5, DisplayName: "Claude Haiku 3.5", Aliases: []Model{"claude-3-5-haiku-latest"},
+		ContextWindow: 200000, MaxOutputTokens: 8192,
+		Tools: map[ToolName]string{
+			WEB_SEARCH: "web_search_20250305",
+		},
+		Pricing: Pricing{Input: 0.8, Output: 4, CacheWrite: 1, CacheRead: 0.08},
+	},
+}
+
+// ModelRegistry looks up the capabilities of models. It is safe for
+// concurrent use.
+type ModelRegistry struct {
+	mu      sync.RWMutex
+	models  map[Model]ModelInfo
+	aliases map[Model]Model
+}
+
+// DefaultModels holds KNOWN_MODELS and any models registered since
+var DefaultModels = NewModelRegistry(KNOWN_MODELS...)
+
+func NewModelRegistry(models ...ModelInfo) *ModelRegistry {
+	r := &ModelRegistry{models: make(map[Model]ModelInfo), aliases: make(map[Model]Model)}
+	for _, m := range models {
+		r.Register(m)
+	}
+	return r
+}
+
+// Register adds or replaces a model
+func (r *ModelRegistry) Register(info ModelInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[info.Model] = info
+	for _, alias := range info.Aliases {
+		r.aliases[alias] = info.Model
+	}
+}
+
+func (r *ModelRegistry) Lookup(model Model) (ModelInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if target, ok := r.aliases[model]; ok {
+		model = target
+	}
+	info, ok := r.models[model]
+	if !ok {
+		return ModelInfo{}, fmt.Errorf("Unknown model '%v'. Refresh the model registry from the API or register the model", model)
+	}
+	return info, nil
+}
+
+// Models returns every registered model, sorted by id
+func (r *ModelRegistry) Models() []ModelInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	models := make([]ModelInfo, 0, len(r.models))
+	for _, m := range r.models {
+		models = append(models, m)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Model < models[j].Model })
+	return models
+}
+
+var modelDateSuffix = regexp.MustCompile(`-\d{8}$`)
+
+// Discover registers a model reported by the API. The API does not report
+// capabilities, so they are copied from a registered model of the same
+// family, e.g. an earlier snapshot. Models of an unknown family are
+// registered without limits, thinking, tools or pricing.
+func (r *ModelRegistry) Discover(model Model, displayName string) ModelInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.models[model]
+	if !ok {
+		info = r.sameFamily(model)
+		info.Model = model
+		info.Aliases = nil
+	}
+	if displayName != "" {
+		info.DisplayName = displayName
+	}
+	r.models[model] = info
+	return info
+}
+
+// sameFamily returns a registered model whose id differs from model only in
+// its date
+func (r *ModelRegistry) sameFamily(model Model) ModelInfo {
+	family := modelDateSuffix.ReplaceAllString(string(model), "")
+	for _, known := range r.models {
+		if modelDateSuffix.ReplaceAllString(string(known.Model), "") == family {
+			return known
+		}
+	}
+	return ModelInfo{}
+}
+
+// ModelEntry is a model as listed by the /v1/models endpoint
+type ModelEntry struct {
+	Id          Model  `json:"id"`
+	Type        string `json:"type"`
+	DisplayName string `json:"display_name"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type ModelList struct {
+	Data    []ModelEntry `json:"data"`
+	FirstId string       `json:"first_id"`
+	LastId  string       `json:"last_id"`
+	HasMore bool         `json:"has_more"`
+}
@@ -0,0 +1,99 @@
+package anthropic_test
+
+import (
+	"testing"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func TestModelRegistryLookup(t *testing.T) {
+	registry := anthropic.NewModelRegistry(anthropic.KNOWN_MODELS...)
+
+	tests := []struct {
+		name  string
+		model anthropic.Model
+		want  anthropic.Model
+		err   bool
+	}{
+		{"snapshot", anthropic.SONNET_4, anthropic.SONNET_4, false},
+		{"alias", "claude-sonnet-4-0", anthropic.SONNET_4, false},
+		{"unknown", "claude-unknown-1", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := registry.Lookup(tt.model)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if info.Model != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, info.Model)
+			}
+		})
+	}
+}
+
+func TestModelRegistryDiscover(t *testing.T) {
+	tests := []struct {
+		name          string
+		model         anthropic.Model
+		displayName   string
+		wantName      string
+		wantMaxOutput int
+		wantThinking  bool
+		wantAliases   bool
+	}{
+		{"known model keeps its capabilities", anthropic.SONNET_4, "Claude Sonnet 4 (new name)", "Claude Sonnet 4 (new name)", 64000, true, true},
+		{"known model without a display name", anthropic.HAIKU_3_5, "", "Claude Haiku 3.5", 8192, false, true},
+		{"new snapshot of a known family", "claude-sonnet-4-20991231", "Claude Sonnet 4 (next)", "Claude Sonnet 4 (next)", 64000, true, false},
+		{"unknown family", "claude-mystery-1-20991231", "Claude Mystery", "Claude Mystery", 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := anthropic.NewModelRegistry(anthropic.KNOWN_MODELS...)
+			info := registry.Discover(tt.model, tt.displayName)
+			if info.Model != tt.model || info.DisplayName != tt.wantName || info.MaxOutputTokens != tt.wantMaxOutput || info.Thinking != tt.wantThinking {
+				t.Errorf("Expected %v %q with %v output tokens and thinking %v, got %+v", tt.model, tt.wantName, tt.wantMaxOutput, tt.wantThinking, info)
+			}
+			if got, err := registry.Lookup(tt.model); err != nil || got.DisplayName != tt.wantName {
+				t.Errorf("Expected the discovered model to be registered, got %+v (%v)", got, err)
+			}
+			// Aliases keep resolving to the snapshot they were registered for
+			if len(info.Aliases) > 0 != tt.wantAliases {
+				t.Errorf("Expected aliases %v, got %v", tt.wantAliases, info.Aliases)
+			}
+		})
+	}
+}
+
+func TestSupportsToolType(t *testing.T) {
+	haiku, _ := anthropic.DefaultModels.Lookup(anthropic.HAIKU_3_5)
+	sonnet, _ := anthropic.DefaultModels.Lookup(anthropic.SONNET_4)
+
+	tests := []struct {
+		name     string
+		info     anthropic.ModelInfo
+		toolType string
+		want     bool
+	}{
+		{"custom tools everywhere", haiku, "custom", true},
+		{"untyped tools everywhere", haiku, "", true},
+		{"supported version", sonnet, "text_editor_20250728", true},
+		{"older version", sonnet, "text_editor_20250124", false},
+		{"unsupported tool", haiku, "bash_20250124", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.SupportsToolType(tt.toolType); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPricingCost(t *testing.T) {
+	pricing := anthropic.Pricing{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3}
+	usage := anthropic.MessagesUsage{InputTokens: 1000000, OutputTokens: 100000, CacheCreationInputTokens: 200000, CacheReadInputTokens: 1000000}
+	if got, want := pricing.Cost(usage), 3+1.5+0.75+0.3; got != want {
+		t.Errorf("Expected $%v, got $%v", want, got)
+	}
+}
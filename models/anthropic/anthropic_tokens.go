@@ -0,0 +1,80 @@
+package anthropic
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+type TokenCount struct {
+	InputTokens int `json:"input_tokens"`
+}
+
+// Rough sizes used by EstimateTokens
+const (
+	CHARS_PER_TOKEN       int = 4
+	IMAGE_TOKENS          int = 1600 // A large image; smaller images use fewer
+	PDF_BYTES_PER_PAGE    int = 50000
+	PDF_TOKENS_PER_PAGE   int = 2500 // Text plus the page image
+	REQUEST_BASE_TOKENS   int = 10
+	TOOLS_OVERHEAD_TOKENS int = 350 // System prompt added when tools are used
+)
+
+// EstimateTokens approximates the input tokens of a request without calling
+// the API. It errs on the high side and is meant for offline use or to skip
+// exact counting of requests that are clearly small.
+func EstimateTokens(request *AnthropicMessagesRequest) int {
+	tokens := REQUEST_BASE_TOKENS + len(request.System.Text())/CHARS_PER_TOKEN
+	for _, m := range request.Messages {
+		tokens += estimateContent(m.Content)
+	}
+	if len(request.Tools) > 0 {
+		tokens += TOOLS_OVERHEAD_TOKENS
+		for _, tool := range request.Tools {
+			tokens += estimateJson(tool)
+		}
+	}
+	return tokens
+}
+
+func estimateContent(content []Content) int {
+	tokens := 0
+	for _, c := range content {
+		switch c := c.(type) {
+		case *ImageContent:
+			tokens += IMAGE_TOKENS
+		case *DocumentContent:
+			tokens += estimateDocument(c)
+		case ToolResultContent:
+			tokens += estimateContent(c.Content)
+		case *ToolResultContent:
+			tokens += estimateContent(c.Content)
+		default:
+			tokens += estimateJson(c)
+		}
+	}
+	return tokens
+}
+
+func estimateDocument(document *DocumentContent) int {
+	switch document.Source.Type {
+	case SOURCE_TEXT:
+		return len(document.Source.Data) / CHARS_PER_TOKEN
+	case SOURCE_CONTENT:
+		return estimateContent(document.Source.Content)
+	case SOURCE_BASE64:
+		size := base64.StdEncoding.DecodedLen(len(document.Source.Data))
+		pages := size/PDF_BYTES_PER_PAGE + 1
+		return pages * PDF_TOKENS_PER_PAGE
+	default:
+		// The size of files and urls is unknown
+		return PDF_TOKENS_PER_PAGE
+	}
+}
+
+func estimateJson(v any) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(data) / CHARS_PER_TOKEN
+}
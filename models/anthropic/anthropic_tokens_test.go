@@ -0,0 +1,67 @@
+package anthropic_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/frozenkro/go-agent/models/anthropic"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	user := func(content ...anthropic.Content) []anthropic.Message {
+		return []anthropic.Message{{Role: anthropic.USER, Content: content}}
+	}
+	tool := anthropic.NewCustomTool("get_weather", "Get the weather", map[string]any{"type": "object"})
+	toolJson, _ := json.Marshal(tool)
+
+	tests := []struct {
+		name    string
+		request anthropic.AnthropicMessagesRequest
+		want    int
+	}{
+		{"empty", anthropic.AnthropicMessagesRequest{}, anthropic.REQUEST_BASE_TOKENS},
+		{
+			"system prompt",
+			anthropic.AnthropicMessagesRequest{System: anthropic.NewSystemPrompt(strings.Repeat("a", 400))},
+			anthropic.REQUEST_BASE_TOKENS + 100,
+		},
+		{
+			"image",
+			anthropic.AnthropicMessagesRequest{Messages: user(anthropic.NewImageUrlContent("https://example.com/a.png"))},
+			anthropic.REQUEST_BASE_TOKENS + anthropic.IMAGE_TOKENS,
+		},
+		{
+			"text document",
+			anthropic.AnthropicMessagesRequest{Messages: user(anthropic.NewTextDocumentContent(strings.Repeat("a", 400)))},
+			anthropic.REQUEST_BASE_TOKENS + 100,
+		},
+		{
+			"pdf pages",
+			anthropic.AnthropicMessagesRequest{Messages: user(anthropic.NewPdfDocumentContent(make([]byte, anthropic.PDF_BYTES_PER_PAGE*2)))},
+			anthropic.REQUEST_BASE_TOKENS + 3*anthropic.PDF_TOKENS_PER_PAGE,
+		},
+		{
+			"document of unknown size",
+			anthropic.AnthropicMessagesRequest{Messages: user(anthropic.NewDocumentFileContent("file_1"))},
+			anthropic.REQUEST_BASE_TOKENS + anthropic.PDF_TOKENS_PER_PAGE,
+		},
+		{
+			"image in tool result",
+			anthropic.AnthropicMessagesRequest{Messages: user(&anthropic.ToolResultContent{Content: []anthropic.Content{anthropic.NewImageFileContent("file_1")}})},
+			anthropic.REQUEST_BASE_TOKENS + anthropic.IMAGE_TOKENS,
+		},
+		{
+			"tools",
+			anthropic.AnthropicMessagesRequest{Tools: []anthropic.AnthropicToolSpec{tool}},
+			anthropic.REQUEST_BASE_TOKENS + anthropic.TOOLS_OVERHEAD_TOKENS + len(toolJson)/anthropic.CHARS_PER_TOKEN,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := anthropic.EstimateTokens(&tt.request); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
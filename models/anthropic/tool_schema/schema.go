@@ -0,0 +1,143 @@
+package toolschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// For returns a JSON Schema describing the JSON encoding of T, which must be
+// a struct. Fields are named by their json tags and required unless they are
+// pointers or tagged omitempty. A `description:"..."` tag documents a field
+// and an `enum:"a,b,c"` tag limits a string field to the listed values.
+func For[T any]() (map[string]any, error) {
+	return FromType(reflect.TypeFor[T]())
+}
+
+func FromType(t reflect.Type) (map[string]any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Tool input must be a struct, got %v", t)
+	}
+	return typeSchema(t, map[reflect.Type]bool{})
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) (map[string]any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	case rawMessageType:
+		return map[string]any{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("Map keys must be strings, got %v", t.Key())
+		}
+		values, err := typeSchema(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if seen[t] {
+			return nil, fmt.Errorf("Recursive type %v has no finite schema", t)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		return structSchema(t, seen)
+	}
+	return nil, fmt.Errorf("Type %v has no JSON Schema", t)
+}
+
+func structSchema(t reflect.Type, seen map[reflect.Type]bool) (map[string]any, error) {
+	properties := map[string]any{}
+	required := []string{}
+	if err := addFields(t, seen, properties, &required); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}, nil
+}
+
+// addFields adds the fields of t to properties, flattening embedded structs
+// the way encoding/json does
+func addFields(t reflect.Type, seen map[reflect.Type]bool, properties map[string]any, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := addFields(embedded, seen, properties, required); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema, err := typeSchema(field.Type, seen)
+		if err != nil {
+			return fmt.Errorf("Field %v: %w", field.Name, err)
+		}
+		if description := field.Tag.Get("description"); description != "" {
+			schema["description"] = description
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			schema["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = schema
+
+		optional := field.Type.Kind() == reflect.Pointer || strings.Contains(","+opts+",", ",omitempty,")
+		if !optional {
+			*required = append(*required, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package toolschema_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	toolschema "github.com/frozenkro/go-agent/models/anthropic/tool_schema"
+)
+
+type Base struct {
+	Id string `json:"id"`
+}
+
+type Item struct {
+	Name  string `json:"name"`
+	Count uint   `json:"count,omitempty"`
+}
+
+type Node struct {
+	Children []Node `json:"children"`
+}
+
+func object(properties map[string]any, required ...string) map[string]any {
+	return map[string]any{"type": "object", "properties": properties, "required": append([]string{}, required...), "additionalProperties": false}
+}
+
+func TestFromType(t *testing.T) {
+	tests := []struct {
+		name string
+		t    reflect.Type
+		want map[string]any
+		err  string
+	}{
+		{
+			name: "scalars and tags",
+			t: reflect.TypeFor[struct {
+				City    string  `json:"city" description:"City name"`
+				Units   string  `json:"units" enum:"metric,imperial"`
+				Days    int     `json:"days,omitempty"`
+				Precise *bool   `json:"precise"`
+				Ratio   float64 `json:"ratio"`
+				Skipped string  `json:"-"`
+				hidden  string
+				Untyped any      `json:"untyped"`
+				Tags    []string `json:"tags"`
+			}](),
+			want: object(map[string]any{
+				"city":    map[string]any{"type": "string", "description": "City name"},
+				"units":   map[string]any{"type": "string", "enum": []string{"metric", "imperial"}},
+				"days":    map[string]any{"type": "integer"},
+				"precise": map[string]any{"type": "boolean"},
+				"ratio":   map[string]any{"type": "number"},
+				"untyped": map[string]any{},
+				"tags":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			}, "city", "units", "ratio", "untyped", "tags"),
+		},
+		{
+			name: "special types",
+			t: reflect.TypeFor[struct {
+				At  time.Time       `json:"at"`
+				Raw json.RawMessage `json:"raw"`
+			}](),
+			want: object(map[string]any{
+				"at":  map[string]any{"type": "string", "format": "date-time"},
+				"raw": map[string]any{},
+			}, "at", "raw"),
+		},
+		{
+			name: "nested structs, maps and embedding",
+			t: reflect.TypeFor[*struct {
+				Base
+				Items  []Item          `json:"items"`
+				Counts map[string]uint `json:"counts"`
+			}](),
+			want: object(map[string]any{
+				"id": map[string]any{"type": "string"},
+				"items": map[string]any{"type": "array", "items": object(map[string]any{
+					"name":  map[string]any{"type": "string"},
+					"count": map[string]any{"type": "integer", "minimum": 0},
+				}, "name")},
+				"counts": map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "integer", "minimum": 0}},
+			}, "id", "items", "counts"),
+		},
+		{name: "not a struct", t: reflect.TypeFor[[]Item](), err: "must be a struct"},
+		{name: "recursive type", t: reflect.TypeFor[Node](), err: "Recursive type"},
+		{name: "map with integer keys", t: reflect.TypeFor[struct{ M map[int]string }](), err: "Map keys must be strings"},
+		{name: "unsupported field", t: reflect.TypeFor[struct{ C chan int }](), err: "Field C"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toolschema.FromType(tt.t)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
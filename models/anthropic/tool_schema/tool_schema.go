@@ -4,3 +4,46 @@ type BashToolInput struct {
 	Command string `json:"command"`
 	Restart bool   `json:"restart"`
 }
+
+type BashSessionToolInput struct {
+	Session string `json:"session"`
+	Command string `json:"command"`
+	Restart bool   `json:"restart"`
+}
+
+type BashJobAction string
+
+const (
+	JOB_START BashJobAction = "start"
+	JOB_POLL  BashJobAction = "poll"
+	JOB_SEND  BashJobAction = "send"
+	JOB_KILL  BashJobAction = "kill"
+	JOB_LIST  BashJobAction = "list"
+)
+
+type BashJobToolInput struct {
+	Action  BashJobAction `json:"action"`
+	Job     string        `json:"job"`
+	Command string        `json:"command"`
+	Input   string        `json:"input"`
+}
+
+type TextEditorCommand string
+
+const (
+	EDITOR_VIEW        TextEditorCommand = "view"
+	EDITOR_CREATE      TextEditorCommand = "create"
+	EDITOR_STR_REPLACE TextEditorCommand = "str_replace"
+	EDITOR_INSERT      TextEditorCommand = "insert"
+)
+
+type TextEditorToolInput struct {
+	Command    TextEditorCommand `json:"command"`
+	Path       string            `json:"path"`
+	ViewRange  []int             `json:"view_range" mapstructure:"view_range"`
+	FileText   string            `json:"file_text" mapstructure:"file_text"`
+	OldStr     string            `json:"old_str" mapstructure:"old_str"`
+	NewStr     string            `json:"new_str" mapstructure:"new_str"`
+	InsertLine int               `json:"insert_line" mapstructure:"insert_line"`
+	InsertText string            `json:"insert_text" mapstructure:"insert_text"`
+}